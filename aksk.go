@@ -43,30 +43,48 @@ const (
 	headerBodyHash = `x-auth-body-hash`
 	// headerRandomStr 随机字符串
 	headerRandomStr = `x-auth-random-str`
+	// headerTrailerSignature 尾部中x-auth-body-hash的签名
+	headerTrailerSignature = `x-auth-trailer-signature`
 )
 
+// 默认的时间戳有效范围, 可以使用WithTimestampWindow修改
 const (
 	maxDuration = 5 * time.Minute
 	minDuration = -1 * time.Minute
 )
 
-// parseTimestamp 解析时间戳
-func parseTimestamp(s string) error {
+// parseTimestamp 解析时间戳, 时间戳在过去maxAge或者未来maxAhead之内有效
+func parseTimestamp(s string, maxAge, maxAhead time.Duration) (time.Time, error) {
 	if s == "" {
-		return ErrTimestampEmpty
+		return time.Time{}, ErrTimestampEmpty
 	}
 	n, err := strconv.ParseInt(s, 10, 64)
 	if err != nil {
-		return err
+		return time.Time{}, err
 	}
 	t := time.Unix(n, 0)
 	d := time.Now().Sub(t)
-	if d > maxDuration {
-		return ErrTimestampExpired
-	} else if d < minDuration {
-		return ErrTimestampInvalid
+	if d > maxAge {
+		return time.Time{}, ErrTimestampExpired
+	} else if d < -maxAhead {
+		return time.Time{}, ErrTimestampInvalid
 	}
-	return nil
+	return t, nil
+}
+
+// signedHeaders 参与签名的头部
+type signedHeaders struct {
+	accessKey string
+	timestamp string
+	randomStr string
+	bodyHash  string
+	// optional 可选的签名头部, 如x-auth-credential-scope, 客户端未发送时为空
+	optional []string
+}
+
+// elems 返回参与签名的元素
+func (h signedHeaders) elems() []string {
+	return append([]string{h.accessKey, h.timestamp, h.randomStr, h.bodyHash}, h.optional...)
 }
 
 func hashSum(b []byte) []byte {
@@ -77,13 +95,24 @@ func hashSum(b []byte) []byte {
 }
 
 func hmacSum(key []byte, elems ...string) []byte {
+	return hmacRaw(key, stringToSign(elems...))
+}
+
+// hmacRaw 不排序直接计算s的HMAC
+func hmacRaw(key []byte, s string) []byte {
 	h := hmac.New(hashFunc, key)
-	sort.Strings(elems)
-	s := strings.Join(elems, "")
 	h.Write([]byte(s))
 	return h.Sum(nil)
 }
 
+// stringToSign 将签名元素按照字典序排序后拼接成待签名的字符串
+func stringToSign(elems ...string) string {
+	ss := make([]string, len(elems))
+	copy(ss, elems)
+	sort.Strings(ss)
+	return strings.Join(ss, "")
+}
+
 // validBytes 通过计算请求b的sha256值验证请求内容
 // 如果b长度为0, 返回真; 否则检查mac和编码器计算的Mac是否一致
 func validBytes(b []byte, s string) error {
@@ -103,6 +132,21 @@ func validBytes(b []byte, s string) error {
 	return ErrBodyHashInvalid
 }
 
+// validBytesStrict 严格模式的validBytes, 空的请求内容也必须与空字符串的哈希值一致
+func validBytesStrict(b []byte, s string) error {
+	if s == "" {
+		return ErrBodyHashEmpty
+	}
+	mac, err := encoder.DecodeString(s)
+	if err != nil {
+		return ErrBodyHashInvalid
+	}
+	if !bytes.Equal(mac, hashSum(b)) {
+		return ErrBodyInvalid
+	}
+	return nil
+}
+
 // validSignature 校验头部签名
 func validSignature(sk, sign string, elems ...string) error {
 	// 解码签名,得道原始的字节切片
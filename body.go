@@ -0,0 +1,213 @@
+package ginaksk
+
+import (
+	"bytes"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bodyKey gin.Context中保存流式验证的请求内容的key
+const bodyKey = "ginaksk.body"
+
+// verifyingBody 处理函数读取时验证的请求内容, bodyErr返回验证失败的错误
+type verifyingBody interface {
+	io.Reader
+	bodyErr() error
+}
+
+// ErrBodyTooLarge 请求内容超过大小限制
+var ErrBodyTooLarge = newStatusError(http.StatusRequestEntityTooLarge, "请求内容过大")
+
+// limitedBody 限制大小的请求内容, 超过限制时Read返回ErrBodyTooLarge
+type limitedBody struct {
+	rc io.ReadCloser
+	n  int64
+}
+
+// Read 读取请求内容, 超过限制时返回ErrBodyTooLarge
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.n < 0 {
+		return 0, ErrBodyTooLarge
+	}
+	// 多读取一个字节判断是否超过限制
+	if int64(len(p)) > b.n+1 {
+		p = p[:b.n+1]
+	}
+	n, err := b.rc.Read(p)
+	if int64(n) > b.n {
+		n = int(b.n)
+		b.n = -1
+		return n, ErrBodyTooLarge
+	}
+	b.n -= int64(n)
+	return n, err
+}
+
+// Close 关闭请求内容
+func (b *limitedBody) Close() error {
+	return b.rc.Close()
+}
+
+// limitBody 限制请求内容不超过n字节, Content-Length超过限制时直接返回ErrBodyTooLarge
+func limitBody(c *gin.Context, n int64) error {
+	if n <= 0 || c.Request.Body == nil {
+		return nil
+	}
+	if c.Request.ContentLength > n {
+		return ErrBodyTooLarge
+	}
+	c.Request.Body = &limitedBody{rc: c.Request.Body, n: n}
+	return nil
+}
+
+// hashingBody 边读取边计算哈希值的请求内容, 读取到EOF时与x-auth-body-hash比较,
+// 不一致时Read返回ErrBodyInvalid代替io.EOF
+type hashingBody struct {
+	rc io.ReadCloser
+	h  hash.Hash
+	n  int64
+	// expected 读取到EOF时返回期望的哈希值
+	expected func() (string, error)
+	// strict 严格模式, 空的请求内容也检查哈希值
+	strict bool
+	// length 严格模式下期望的请求内容长度, 小于0时不检查
+	length int64
+	// digest 不为nil时比较请求头部中的摘要, 代替x-auth-body-hash
+	digest *digester
+	done   bool
+	err    error
+}
+
+func newHashingBody(rc io.ReadCloser, expected string) *hashingBody {
+	return &hashingBody{rc: rc, h: hashFunc(), expected: func() (string, error) { return expected, nil }, length: -1}
+}
+
+// Read 读取请求内容并计算哈希值
+func (b *hashingBody) Read(p []byte) (int, error) {
+	if b.done {
+		if b.err != nil {
+			return 0, b.err
+		}
+		return 0, io.EOF
+	}
+	n, err := b.rc.Read(p)
+	b.h.Write(p[:n])
+	if b.digest != nil {
+		b.digest.Write(p[:n])
+	}
+	b.n += int64(n)
+	switch err {
+	case io.EOF:
+		b.done = true
+		if b.err = b.verify(); b.err != nil {
+			return n, b.err
+		}
+	case ErrBodyTooLarge:
+		b.err = err
+	}
+	return n, err
+}
+
+// Close 关闭请求内容
+func (b *hashingBody) Close() error {
+	return b.rc.Close()
+}
+
+// bodyErr 返回验证请求内容的错误
+func (b *hashingBody) bodyErr() error {
+	return b.err
+}
+
+// verify 比较请求内容的哈希值
+func (b *hashingBody) verify() error {
+	if b.digest != nil {
+		if b.length >= 0 && b.n != b.length {
+			return ErrContentLengthMismatch
+		}
+		return b.digest.verify()
+	}
+	expected, err := b.expected()
+	if err != nil {
+		return err
+	}
+	return verifySum(b.h.Sum(nil), b.n, b.length, expected, b.strict)
+}
+
+// verifySum 比较长度为n的请求内容的哈希值sum和expected, length小于0时不检查长度;
+// 与validBytes一样, 空的请求内容不检查; 严格模式与validBytesStrict一样
+func verifySum(sum []byte, n, length int64, expected string, strict bool) error {
+	if length >= 0 && n != length {
+		return ErrContentLengthMismatch
+	}
+	if n == 0 && !strict {
+		return nil
+	}
+	mac, err := encoder.DecodeString(expected)
+	if err != nil {
+		if strict {
+			return ErrBodyHashInvalid
+		}
+		return ErrBodyInvalid
+	}
+	if !bytes.Equal(mac, sum) {
+		return ErrBodyInvalid
+	}
+	return nil
+}
+
+// streamBody 使用hashingBody替换请求内容, 由处理函数读取, strict为true时使用严格模式
+func streamBody(c *gin.Context, bodyhash string, strict bool) {
+	if c.Request.Body == nil {
+		return
+	}
+	b := newHashingBody(c.Request.Body, bodyhash)
+	if strict {
+		b.strict, b.length = true, c.Request.ContentLength
+	}
+	c.Request.Body = b
+	c.Set(bodyKey, b)
+}
+
+// readStream 读取流式验证的请求内容直到EOF, 验证通过后使用读取的内容替换请求内容
+func readStream(c *gin.Context) error {
+	v, ok := c.Get(bodyKey)
+	if !ok {
+		return nil
+	}
+	b := v.(verifyingBody)
+	body, err := ioutil.ReadAll(b)
+	if e := b.bodyErr(); e != nil {
+		return e
+	}
+	if err != nil {
+		return readError(err)
+	}
+	c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return nil
+}
+
+// nextStream 执行后续的处理函数, 然后读取处理函数没有读取的请求内容直到EOF, 保证请求内容都经过验证;
+// 请求内容不一致时, 如果还没有写入响应则使用错误处理函数终止请求, 否则记录日志
+func nextStream(c *gin.Context) {
+	v, ok := c.Get(bodyKey)
+	if !ok {
+		c.Next()
+		return
+	}
+	b := v.(verifyingBody)
+	c.Next()
+	io.Copy(ioutil.Discard, b)
+	err := b.bodyErr()
+	if err == nil {
+		return
+	}
+	if c.Writer.Written() {
+		logger.Printf("验证请求错误: %s, 已经写入响应", err)
+		return
+	}
+	abort(c, err)
+}
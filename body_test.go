@@ -0,0 +1,205 @@
+package ginaksk
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestStreamingBody(t *testing.T) {
+	t.Cleanup(cleanup)
+	gin.SetMode(gin.TestMode)
+	keyFn := func(string) string {
+		return "sk"
+	}
+	var readErr error
+	e := gin.New()
+	e.Use(Validate(keyFn, false, nil, WithStreamingBody()))
+	e.POST("/upload", func(c *gin.Context) {
+		_, readErr = ioutil.ReadAll(c.Request.Body)
+	})
+	e.POST("/respond", func(c *gin.Context) {
+		if _, readErr = ioutil.ReadAll(c.Request.Body); readErr != nil {
+			c.String(http.StatusBadRequest, readErr.Error())
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+	// 只读取开头的请求内容
+	e.POST("/partial", func(c *gin.Context) {
+		_, readErr = c.Request.Body.Read(make([]byte, 10))
+	})
+	body := bytes.Repeat([]byte("0123456789"), 100000)
+	request := func(path string, body []byte, tamper bool) *http.Request {
+		f, _ := NewRequestFunc("ak", "sk")
+		req, _ := f(context.TODO(), "POST", `http://localhost`+path, body)
+		if tamper {
+			b := append([]byte(nil), body...)
+			b[len(b)-1] = 'x'
+			req.Body = ioutil.NopCloser(bytes.NewReader(b))
+		}
+		return req
+	}
+	tests := []struct {
+		name    string
+		req     *http.Request
+		want    int
+		wantErr error
+	}{
+		{name: "Ok", req: request("/upload", body, false), want: http.StatusOK},
+		{name: "Tampered", req: request("/upload", body, true), want: http.StatusUnauthorized, wantErr: ErrBodyInvalid},
+		{name: "HandlerResponded", req: request("/respond", body, true), want: http.StatusBadRequest, wantErr: ErrBodyInvalid},
+		{name: "Empty", req: request("/upload", nil, false), want: http.StatusOK},
+		{name: "PartialOk", req: request("/partial", body, false), want: http.StatusOK},
+		{name: "PartialTampered", req: request("/partial", body, true), want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readErr = nil
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, tt.req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+			if readErr != tt.wantErr {
+				t.Errorf("read error = %v, want %v", readErr, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	t.Cleanup(cleanup)
+	gin.SetMode(gin.TestMode)
+	credFn := func(ak string) (*Credential, error) {
+		cred := &Credential{AccessKey: ak, SecretKey: "sk"}
+		if ak == "uploader" {
+			cred.MaxBodySize = 1000
+		}
+		return cred, nil
+	}
+	e := gin.New()
+	e.POST("/buffered", New(credFn, WithMaxBodySize(100)), func(c *gin.Context) { c.Status(http.StatusOK) })
+	e.POST("/streaming", New(credFn, WithMaxBodySize(100), WithStreamingBody()), func(c *gin.Context) {
+		ioutil.ReadAll(c.Request.Body)
+	})
+	e.POST("/optional", New(credFn, WithMaxBodySize(100), WithOptionalAuth()), func(c *gin.Context) {
+		if _, err := ioutil.ReadAll(c.Request.Body); err != nil {
+			c.String(http.StatusRequestEntityTooLarge, err.Error())
+		}
+	})
+	anonymous := func(n int, chunked bool) *http.Request {
+		req := httptest.NewRequest("POST", "/optional", bytes.NewReader(bytes.Repeat([]byte("a"), n)))
+		if chunked {
+			req.ContentLength = -1
+		}
+		return req
+	}
+	request := func(ak, path string, n int, chunked bool) *http.Request {
+		f, _ := NewRequestFunc(ak, "sk")
+		req, _ := f(context.TODO(), "POST", `http://localhost`+path, bytes.Repeat([]byte("a"), n))
+		if chunked {
+			req.ContentLength = -1
+		}
+		return req
+	}
+	tests := []struct {
+		name string
+		req  *http.Request
+		want int
+	}{
+		{name: "Small", req: request("ak", "/buffered", 100, false), want: http.StatusOK},
+		{name: "ContentLength", req: request("ak", "/buffered", 101, false), want: http.StatusRequestEntityTooLarge},
+		{name: "Chunked", req: request("ak", "/buffered", 101, true), want: http.StatusRequestEntityTooLarge},
+		{name: "PerKey", req: request("uploader", "/buffered", 1000, false), want: http.StatusOK},
+		{name: "PerKeyTooLarge", req: request("uploader", "/buffered", 1001, true), want: http.StatusRequestEntityTooLarge},
+		{name: "Streaming", req: request("ak", "/streaming", 100, true), want: http.StatusOK},
+		{name: "StreamingTooLarge", req: request("ak", "/streaming", 101, true), want: http.StatusRequestEntityTooLarge},
+		{name: "Anonymous", req: anonymous(100, true), want: http.StatusOK},
+		{name: "AnonymousContentLength", req: anonymous(101, false), want: http.StatusRequestEntityTooLarge},
+		{name: "AnonymousChunked", req: anonymous(1000, true), want: http.StatusRequestEntityTooLarge},
+		{name: "PerKeyOverGlobal", req: request("uploader", "/streaming", 1000, true), want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, tt.req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d, body: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestStrictBodyHash(t *testing.T) {
+	t.Cleanup(cleanup)
+	gin.SetMode(gin.TestMode)
+	credFn := func(ak string) (*Credential, error) {
+		return &Credential{AccessKey: ak, SecretKey: "sk"}, nil
+	}
+	// 保持头部不变, 替换请求内容
+	withBody := func(body string, n int64) func(req *http.Request) {
+		return func(req *http.Request) {
+			req.Body = ioutil.NopCloser(bytes.NewReader([]byte(body)))
+			if n >= 0 {
+				req.ContentLength = n
+			}
+		}
+	}
+	tests := []struct {
+		name    string
+		body    string
+		strict  bool
+		modify  func(req *http.Request)
+		wantErr error
+	}{
+		{name: "Ok", body: "abc", strict: true},
+		{name: "EmptyOk", strict: true},
+		{name: "EmptyWithoutHash", wantErr: ErrBodyHashEmpty},
+		{name: "BodyWithoutHash", modify: withBody("abc", 3), wantErr: ErrBodyHashEmpty},
+		{name: "EmptyHashWithBody", strict: true, modify: withBody("abc", 3), wantErr: ErrBodyInvalid},
+		{name: "HashWithoutBody", body: "abc", strict: true, modify: withBody("", 0), wantErr: ErrBodyInvalid},
+		{name: "ContentLengthShort", body: "abc", strict: true, modify: withBody("ab", -1), wantErr: ErrContentLengthMismatch},
+		{name: "ContentLengthLong", body: "abc", strict: true, modify: withBody("abcd", -1), wantErr: ErrContentLengthMismatch},
+		{name: "ContentLengthUnknown", body: "abc", strict: true, modify: func(req *http.Request) {
+			req.ContentLength = -1
+		}},
+	}
+	for _, streaming := range []bool{false, true} {
+		for _, tt := range tests {
+			name := tt.name
+			if streaming {
+				name = "Streaming" + name
+			}
+			t.Run(name, func(t *testing.T) {
+				var ropts []RequestOption
+				if tt.strict {
+					ropts = append(ropts, WithRequestStrictBodyHash())
+				}
+				f, _ := NewRequestFunc("ak", "sk", ropts...)
+				req, _ := f(context.TODO(), "POST", `http://localhost/e`, []byte(tt.body))
+				if tt.modify != nil {
+					tt.modify(req)
+				}
+				opts := []Option{WithStrictBodyHash()}
+				if streaming {
+					opts = append(opts, WithStreamingBody())
+				}
+				v := &validator{credFn: credFn, options: newOptions(opts...)}
+				c := &gin.Context{Request: req}
+				err := v.validRequest(c)
+				if err == nil && streaming {
+					_, err = ioutil.ReadAll(c.Request.Body)
+				}
+				if err != tt.wantErr {
+					t.Errorf("error = %v, want %v", err, tt.wantErr)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package ginaksk
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// BodyCanonicalization 计算请求内容哈希值前的规范化方式, 客户端和服务端必须一致
+type BodyCanonicalization int
+
+const (
+	// BodyExact 使用原始字节, New的默认值
+	BodyExact BodyCanonicalization = iota
+	// BodyTrimmed 去除首尾空白, Validate的默认值, 兼容以前的版本
+	BodyTrimmed
+	// BodyCanonicalJSON Content-Type为JSON的请求内容使用RFC 8785(JCS)规范化的JSON, 其他请求内容使用原始字节
+	BodyCanonicalJSON
+)
+
+// String 返回规范化方式的名称
+func (m BodyCanonicalization) String() string {
+	switch m {
+	case BodyExact:
+		return "exact"
+	case BodyTrimmed:
+		return "trimmed"
+	case BodyCanonicalJSON:
+		return "json"
+	}
+	return fmt.Sprintf("BodyCanonicalization(%d)", int(m))
+}
+
+// canonicalize 返回规范化后的请求内容, contentType为请求的Content-Type
+func (m BodyCanonicalization) canonicalize(b []byte, contentType string) ([]byte, error) {
+	switch m {
+	case BodyTrimmed:
+		return bytes.TrimSpace(b), nil
+	case BodyCanonicalJSON:
+		if isJSON(contentType) {
+			return canonicalJSON(b)
+		}
+	}
+	return b, nil
+}
+
+// isJSON 返回Content-Type是否是JSON, 如application/json, application/problem+json
+func isJSON(contentType string) bool {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return t == "application/json" || (strings.HasPrefix(t, "application/") && strings.HasSuffix(t, "+json"))
+}
+
+// canonicalJSON 返回RFC 8785(JCS)规范化的JSON, 空的请求内容保持不变
+func canonicalJSON(b []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(b)) == 0 {
+		return nil, nil
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var buf bytes.Buffer
+	if err := writeJCS(&buf, d); err != nil {
+		return nil, fmt.Errorf("规范化JSON请求内容发生错误: %w", err)
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return nil, errors.New("规范化JSON请求内容发生错误: 包含多个JSON值")
+	}
+	return buf.Bytes(), nil
+}
+
+// jcsMember JSON对象的成员
+type jcsMember struct {
+	key   string
+	value []byte
+}
+
+// writeJCS 从d读取一个JSON值, 按照RFC 8785写入buf
+func writeJCS(buf *bytes.Buffer, d *json.Decoder) error {
+	tok, err := d.Token()
+	if err != nil {
+		return err
+	}
+	switch v := tok.(type) {
+	case json.Delim:
+		switch v {
+		case '[':
+			buf.WriteByte('[')
+			for i := 0; d.More(); i++ {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				if err := writeJCS(buf, d); err != nil {
+					return err
+				}
+			}
+			buf.WriteByte(']')
+		case '{':
+			var members []jcsMember
+			keys := make(map[string]bool)
+			for d.More() {
+				tok, err := d.Token()
+				if err != nil {
+					return err
+				}
+				key, _ := tok.(string)
+				if keys[key] {
+					return fmt.Errorf("重复的键: %s", key)
+				}
+				keys[key] = true
+				var value bytes.Buffer
+				if err := writeJCS(&value, d); err != nil {
+					return err
+				}
+				members = append(members, jcsMember{key: key, value: value.Bytes()})
+			}
+			// 按照UTF-16编码单元排序
+			sort.Slice(members, func(i, j int) bool {
+				return lessUTF16(members[i].key, members[j].key)
+			})
+			buf.WriteByte('{')
+			for i, m := range members {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				writeJCSString(buf, m.key)
+				buf.WriteByte(':')
+				buf.Write(m.value)
+			}
+			buf.WriteByte('}')
+		}
+		// 读取结束的]或}
+		_, err = d.Token()
+		return err
+	case string:
+		writeJCSString(buf, v)
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+		s, err := jcsNumber(f)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(s)
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case nil:
+		buf.WriteString("null")
+	}
+	return nil
+}
+
+// lessUTF16 按照UTF-16编码单元比较字符串
+func lessUTF16(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
+
+// writeJCSString 按照RFC 8785写入字符串, 只转义引号、反斜杠和控制字符
+func writeJCSString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(buf, `\u%04x`, r)
+			} else if r == utf8.RuneError {
+				buf.WriteString("�")
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// jcsNumber 按照ECMAScript的Number.prototype.toString格式化数字
+func jcsNumber(f float64) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", fmt.Errorf("无效的数字: %v", f)
+	}
+	if f == 0 {
+		return "0", nil
+	}
+	if abs := math.Abs(f); abs >= 1e21 || abs < 1e-6 {
+		s := strconv.FormatFloat(f, 'e', -1, 64)
+		// Go的指数至少两位, 如1e-07, ECMAScript为1e-7
+		i := strings.IndexByte(s, 'e')
+		mantissa, exp := s[:i], s[i+2:]
+		exp = strings.TrimLeft(exp, "0")
+		return mantissa + "e" + s[i+1:i+2] + exp, nil
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64), nil
+}
@@ -0,0 +1,94 @@
+package ginaksk
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBodyCanonicalization(t *testing.T) {
+	t.Cleanup(cleanup)
+	credFn := func(ak string) (*Credential, error) {
+		return &Credential{AccessKey: ak, SecretKey: "sk"}, nil
+	}
+	tests := []struct {
+		name        string
+		client      BodyCanonicalization
+		server      BodyCanonicalization
+		body        string
+		sent        string
+		contentType string
+		wantErr     bool
+	}{
+		{name: "ExactTrailingNewline", body: "{\"a\":1}\n", sent: "{\"a\":1}\n"},
+		{name: "ExactInjectedWhitespace", body: `{"a":1}`, sent: ` {"a":1}`, wantErr: true},
+		{name: "TrimmedLegacy", client: BodyTrimmed, server: BodyTrimmed, body: "{\"a\":1}\n", sent: " {\"a\":1}"},
+		{name: "ExactClientTrimmedServer", server: BodyTrimmed, body: "{\"a\":1}\n", sent: "{\"a\":1}\n", wantErr: true},
+		{name: "JSON", client: BodyCanonicalJSON, server: BodyCanonicalJSON, body: `{"b":[1,2],"a":"<x>"}`, sent: "{\n  \"a\": \"<x>\",\n  \"b\": [1, 2]\n}"},
+		{name: "JSONChanged", client: BodyCanonicalJSON, server: BodyCanonicalJSON, body: `{"a":1}`, sent: `{"a":2}`, wantErr: true},
+		{name: "JSONInvalid", client: BodyCanonicalJSON, server: BodyCanonicalJSON, body: `{"a":1}`, sent: `{"a":1`, wantErr: true},
+		{name: "JSONNumbers", client: BodyCanonicalJSON, server: BodyCanonicalJSON, body: `{"n":[1.0,1e2,0.5]}`, sent: `{"n":[1,100,5E-1]}`},
+		{name: "JSONEscapes", client: BodyCanonicalJSON, server: BodyCanonicalJSON, body: `{"s":"\u00e9/"}`, sent: `{"s":"é\/"}`},
+		{name: "JSONDuplicateKey", client: BodyCanonicalJSON, server: BodyCanonicalJSON, body: `{"a":1}`, sent: `{"a":1,"a":2}`, wantErr: true},
+		{name: "NotJSONContentType", client: BodyCanonicalJSON, server: BodyCanonicalJSON, contentType: "text/plain", body: `{"a":1}`, sent: `{ "a":1}`, wantErr: true},
+		{name: "NotJSONExact", client: BodyCanonicalJSON, server: BodyCanonicalJSON, contentType: "text/plain", body: `{"a":1`, sent: `{"a":1`},
+		{name: "JSONSuffix", client: BodyCanonicalJSON, server: BodyCanonicalJSON, contentType: "application/merge-patch+json; charset=utf-8", body: `{"a":1}`, sent: `{ "a" : 1 }`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, _ := NewRequestFunc("ak", "sk", WithRequestBodyCanonicalization(tt.client), WithContentType(tt.contentType))
+			req, err := f(context.TODO(), "POST", `http://localhost/e`, []byte(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Body = ioutil.NopCloser(bytes.NewReader([]byte(tt.sent)))
+			v := &validator{credFn: credFn, options: newOptions(WithBodyCanonicalization(tt.server))}
+			c := &gin.Context{Request: req}
+			if err := v.validRequest(c); (err != nil) != tt.wantErr {
+				t.Errorf("validRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			// 验证后请求内容保持不变
+			if b, _ := ioutil.ReadAll(c.Request.Body); string(b) != tt.sent && !tt.wantErr {
+				t.Errorf("body = %q, want %q", b, tt.sent)
+			}
+		})
+	}
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    string
+		wantErr bool
+	}{
+		// RFC 8785 3.2.2节的示例
+		{
+			name: "RFC8785",
+			in:   `{"numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001], "string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/", "literals": [null, true, false]}`,
+			want: `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`,
+		},
+		// 按照UTF-16编码单元排序, U+1F600的代理对小于U+FB33
+		{name: "SortUTF16", in: `{"\ufb33":1,"\ud83d\ude00":2,"a":3,"":4}`, want: "{\"\":4,\"a\":3,\"\U0001F600\":2,\"\uFB33\":1}"},
+		{name: "Numbers", in: `[-0, 1e21, 1e20, 1e-6, 1e-7, -1.5e-10, 123456789012345680000]`, want: `[0,1e+21,100000000000000000000,0.000001,1e-7,-1.5e-10,123456789012345680000]`},
+		{name: "Nested", in: ` { "b" : { "d" : [ ], "c" : { } } , "a" : "<&>" } `, want: `{"a":"<&>","b":{"c":{},"d":[]}}`},
+		{name: "Empty", in: " \n", want: ""},
+		{name: "Invalid", in: `{"a":}`, wantErr: true},
+		{name: "Trailing", in: `{"a":1} {}`, wantErr: true},
+		{name: "Overflow", in: `[1e400]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := canonicalJSON([]byte(tt.in))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("canonicalJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("canonicalJSON() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
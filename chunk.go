@@ -0,0 +1,231 @@
+package ginaksk
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StreamingPayload x-auth-body-hash的取值, 表示请求内容使用分块签名的流式格式:
+//
+//	hex(size);chunk-signature=signature\r\n
+//	data\r\n
+//	...
+//	0;chunk-signature=signature\r\n
+//	\r\n
+//
+// 每个分块的签名为HMAC(signingKey, "ginaksk-chunk\n" + 前一个分块的签名 + "\n" + 分块内容的哈希值),
+// 第一个分块使用请求头部的x-auth-signature, 最后一个分块的长度为0
+const StreamingPayload = "STREAMING-PAYLOAD"
+
+const (
+	// defaultChunkSize 客户端默认的分块大小
+	defaultChunkSize = 64 << 10
+	// maxChunkSize 服务端接受的最大分块
+	maxChunkSize = 16 << 20
+	// chunkSignaturePrefix 分块头部中签名的前缀
+	chunkSignaturePrefix = ";chunk-signature="
+)
+
+var (
+	// ErrChunkInvalid 分块签名的请求内容格式无效或者不完整
+	ErrChunkInvalid = newStatusError(http.StatusBadRequest, "请求内容分块无效")
+	// ErrStreamingPayloadDenied 服务端没有使用WithStreamingPayload时不允许分块签名的请求内容
+	ErrStreamingPayloadDenied = newStatusError(http.StatusForbidden, "不允许使用分块签名的请求内容")
+)
+
+// chunkStringToSign 返回分块的待签名字符串
+func chunkStringToSign(prev string, data []byte) string {
+	return "ginaksk-chunk\n" + prev + "\n" + encoder.EncodeToString(hashSum(data))
+}
+
+// StreamingRequestFunc 分块签名的流式请求构造函数, 请求内容的长度可以未知
+type StreamingRequestFunc func(ctx context.Context, method, url string, body io.Reader) (*http.Request, error)
+
+// NewStreamingRequestFunc 返回一个StreamingRequestFunc, 请求内容按照chunkSize分块签名,
+// chunkSize小于等于0时为64KB; opts为可选配置, 分块签名的请求内容不规范化
+func NewStreamingRequestFunc(ak, sk string, chunkSize int, opts ...RequestOption) (StreamingRequestFunc, error) {
+	o, err := newRequestOptions(ak, sk, opts)
+	if err != nil {
+		return nil, err
+	}
+	fn := func(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, method, url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("创建HTTP请求发生错误:%w", err)
+		}
+		signature, err := o.sign(req, ak, sk, StreamingPayload)
+		if err != nil {
+			return nil, err
+		}
+		if body == nil {
+			body = http.NoBody
+		}
+		req.Body = ioutil.NopCloser(NewChunkSigner(body, sk, signature, chunkSize))
+		req.ContentLength = -1
+		return req, nil
+	}
+	return fn, nil
+}
+
+// chunkSigner 将请求内容编码为分块签名的流式格式
+type chunkSigner struct {
+	r     io.Reader
+	key   []byte
+	prev  string
+	chunk []byte
+	buf   bytes.Buffer
+	done  bool
+}
+
+// NewChunkSigner 返回一个io.Reader, 将r按照chunkSize编码为分块签名的流式格式, chunkSize小于等于0时为64KB;
+// signingKey为签名请求头部的secretKey或派生的签名密钥, signature为请求头部的x-auth-signature
+func NewChunkSigner(r io.Reader, signingKey, signature string, chunkSize int) io.Reader {
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+	return &chunkSigner{r: r, key: []byte(signingKey), prev: signature, chunk: make([]byte, chunkSize)}
+}
+
+// Read 读取编码后的请求内容
+func (s *chunkSigner) Read(p []byte) (int, error) {
+	for s.buf.Len() == 0 {
+		if s.done {
+			return 0, io.EOF
+		}
+		n, err := io.ReadFull(s.r, s.chunk)
+		if n > 0 {
+			s.writeChunk(s.chunk[:n])
+		}
+		switch err {
+		case nil:
+		case io.EOF, io.ErrUnexpectedEOF:
+			// 最后一个分块
+			s.writeChunk(nil)
+			s.done = true
+		default:
+			return 0, err
+		}
+	}
+	return s.buf.Read(p)
+}
+
+// writeChunk 签名并编码一个分块
+func (s *chunkSigner) writeChunk(data []byte) {
+	s.prev = encoder.EncodeToString(hmacRaw(s.key, chunkStringToSign(s.prev, data)))
+	fmt.Fprintf(&s.buf, "%x%s%s\r\n", len(data), chunkSignaturePrefix, s.prev)
+	s.buf.Write(data)
+	s.buf.WriteString("\r\n")
+}
+
+// chunkDecoder 逐个分块验证签名并解码请求内容, 分块签名无效时Read返回ErrBodyInvalid,
+// 格式无效或者缺少最后一个分块时返回ErrChunkInvalid
+type chunkDecoder struct {
+	r     *bufio.Reader
+	rc    io.Closer
+	key   []byte
+	prev  string
+	chunk []byte
+	done  bool
+	err   error
+}
+
+func newChunkDecoder(rc io.ReadCloser, key, signature string) *chunkDecoder {
+	return &chunkDecoder{r: bufio.NewReaderSize(rc, 512), rc: rc, key: []byte(key), prev: signature}
+}
+
+// Read 读取验证通过的请求内容
+func (d *chunkDecoder) Read(p []byte) (int, error) {
+	for len(d.chunk) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		if d.done {
+			return 0, io.EOF
+		}
+		d.err = d.next()
+	}
+	n := copy(p, d.chunk)
+	d.chunk = d.chunk[n:]
+	return n, nil
+}
+
+// Close 关闭请求内容
+func (d *chunkDecoder) Close() error {
+	return d.rc.Close()
+}
+
+// bodyErr 返回验证请求内容的错误
+func (d *chunkDecoder) bodyErr() error {
+	return d.err
+}
+
+// next 读取并验证下一个分块
+func (d *chunkDecoder) next() error {
+	line, err := d.r.ReadSlice('\n')
+	if err != nil {
+		return d.readErr(err)
+	}
+	s := strings.TrimSuffix(string(line), "\r\n")
+	i := strings.Index(s, chunkSignaturePrefix)
+	if len(s) == len(line) || i < 0 {
+		return ErrChunkInvalid
+	}
+	size, err := strconv.ParseInt(s[:i], 16, 64)
+	if err != nil || size < 0 || size > maxChunkSize {
+		return ErrChunkInvalid
+	}
+	signature := s[i+len(chunkSignaturePrefix):]
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(d.r, data); err != nil {
+		return d.readErr(err)
+	}
+	if !bytes.HasSuffix(data, []byte("\r\n")) {
+		return ErrChunkInvalid
+	}
+	data = data[:size]
+	mac, err := encoder.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, hmacRaw(d.key, chunkStringToSign(d.prev, data))) {
+		return ErrBodyInvalid
+	}
+	d.prev = signature
+	if size == 0 {
+		// 最后一个分块之后不能有其他内容
+		if _, err := d.r.ReadByte(); err != io.EOF {
+			return ErrChunkInvalid
+		}
+		d.done = true
+	}
+	d.chunk = data
+	return nil
+}
+
+// readErr 转换读取分块的错误, 请求内容不完整时返回ErrChunkInvalid
+func (d *chunkDecoder) readErr(err error) error {
+	switch err {
+	case io.EOF, io.ErrUnexpectedEOF, bufio.ErrBufferFull:
+		return ErrChunkInvalid
+	}
+	return readError(err)
+}
+
+// decodeChunks 使用chunkDecoder替换请求内容, 由处理函数读取
+func decodeChunks(c *gin.Context, key, signature string) {
+	rc := c.Request.Body
+	if rc == nil {
+		rc = http.NoBody
+	}
+	d := newChunkDecoder(rc, key, signature)
+	c.Request.Body = d
+	c.Request.ContentLength = -1
+	c.Set(bodyKey, d)
+}
@@ -0,0 +1,175 @@
+package ginaksk
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestChunkSigner(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	tests := []struct {
+		name      string
+		data      []byte
+		chunkSize int
+		tamper    func(b []byte) []byte
+		wantErr   error
+	}{
+		{name: "Ok", data: data, chunkSize: 1024},
+		{name: "ExactChunks", data: data, chunkSize: 1000},
+		{name: "DefaultChunkSize", data: data},
+		{name: "Empty", chunkSize: 1024},
+		{name: "TamperedData", data: data, chunkSize: 1024, tamper: func(b []byte) []byte {
+			b[len(b)/2] ^= 1
+			return b
+		}, wantErr: ErrBodyInvalid},
+		{name: "Truncated", data: data, chunkSize: 1024, tamper: func(b []byte) []byte {
+			// 删除最后一个分块
+			return b[:bytes.LastIndex(b, []byte("0;chunk-signature="))]
+		}, wantErr: ErrChunkInvalid},
+		{name: "TrailingData", data: data, chunkSize: 1024, tamper: func(b []byte) []byte {
+			return append(b, 'x')
+		}, wantErr: ErrChunkInvalid},
+		{name: "InvalidHeader", data: data, chunkSize: 1024, tamper: func(b []byte) []byte {
+			return bytes.Replace(b, []byte(";chunk-signature="), []byte(";signature="), 1)
+		}, wantErr: ErrChunkInvalid},
+		{name: "Reordered", data: data, chunkSize: 5000, tamper: func(b []byte) []byte {
+			// 交换前两个大小相同的分块
+			n := bytes.Index(b[1:], []byte("1388;")) + 1
+			return append(append(append([]byte(nil), b[n:2*n]...), b[:n]...), b[2*n:]...)
+		}, wantErr: ErrBodyInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := ioutil.ReadAll(NewChunkSigner(bytes.NewReader(tt.data), "sk", "seed", tt.chunkSize))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.tamper != nil {
+				b = tt.tamper(b)
+			}
+			got, err := ioutil.ReadAll(newChunkDecoder(ioutil.NopCloser(bytes.NewReader(b)), "sk", "seed"))
+			if err != tt.wantErr {
+				t.Fatalf("read error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !bytes.Equal(got, tt.data) {
+				t.Errorf("decoded %d bytes, want %d", len(got), len(tt.data))
+			}
+		})
+	}
+	// 使用不同的种子签名无法验证
+	b, _ := ioutil.ReadAll(NewChunkSigner(bytes.NewReader(data), "sk", "seed", 0))
+	if _, err := ioutil.ReadAll(newChunkDecoder(ioutil.NopCloser(bytes.NewReader(b)), "sk", "other")); err != ErrBodyInvalid {
+		t.Errorf("read error = %v, want %v", err, ErrBodyInvalid)
+	}
+}
+
+func TestStreamingRequest(t *testing.T) {
+	t.Cleanup(cleanup)
+	gin.SetMode(gin.TestMode)
+	credFn := func(ak string) (*Credential, error) {
+		return &Credential{AccessKey: ak, SecretKey: "sk"}, nil
+	}
+	var content []byte
+	e := gin.New()
+	e.Use(New(credFn, WithStrictBodyHash(), WithStreamingPayload()))
+	e.PUT("/upload", func(c *gin.Context) {
+		content, _ = ioutil.ReadAll(c.Request.Body)
+	})
+	data := bytes.Repeat([]byte("0123456789"), 10000)
+	request := func(sk string, body io.Reader, tamper bool) *http.Request {
+		f, _ := NewStreamingRequestFunc("ak", sk, 4096)
+		req, _ := f(context.TODO(), "PUT", `http://localhost/upload`, body)
+		if tamper {
+			b, _ := ioutil.ReadAll(req.Body)
+			b[len(b)/2] ^= 1
+			req.Body = ioutil.NopCloser(bytes.NewReader(b))
+		}
+		return req
+	}
+	tests := []struct {
+		name        string
+		req         *http.Request
+		want        int
+		wantContent []byte
+	}{
+		{name: "Ok", req: request("sk", bytes.NewReader(data), false), want: http.StatusOK, wantContent: data},
+		{name: "NilBody", req: request("sk", nil, false), want: http.StatusOK},
+		{name: "Tampered", req: request("sk", bytes.NewReader(data), true), want: http.StatusUnauthorized},
+		{name: "InvalidKey", req: request("other", bytes.NewReader(data), false), want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content = nil
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, tt.req)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d, body: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.want == http.StatusOK && !bytes.Equal(content, tt.wantContent) {
+				t.Errorf("content length = %d, want %d", len(content), len(tt.wantContent))
+			}
+		})
+	}
+}
+
+func TestVerifyStreamingRequest(t *testing.T) {
+	t.Cleanup(cleanup)
+	keyFn := func(string) string {
+		return "sk"
+	}
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	chunked := func(tamper bool) *http.Request {
+		f, _ := NewStreamingRequestFunc("ak", "sk", 1024)
+		req, _ := f(context.TODO(), "PUT", `http://localhost/upload`, bytes.NewReader(data))
+		if tamper {
+			b, _ := ioutil.ReadAll(req.Body)
+			b[len(b)/2] ^= 1
+			req.Body = ioutil.NopCloser(bytes.NewReader(b))
+		}
+		return req
+	}
+	hashed := func(tamper bool) *http.Request {
+		f, _ := NewRequestFunc("ak", "sk")
+		req, _ := f(context.TODO(), "PUT", `http://localhost/upload`, data)
+		if tamper {
+			b := append([]byte(nil), data...)
+			b[len(b)-1] ^= 1
+			req.Body = ioutil.NopCloser(bytes.NewReader(b))
+		}
+		return req
+	}
+	tests := []struct {
+		name    string
+		req     *http.Request
+		opts    []Option
+		wantErr error
+	}{
+		{name: "Chunked", req: chunked(false), opts: []Option{WithStreamingPayload()}},
+		{name: "ChunkTampered", req: chunked(true), opts: []Option{WithStreamingPayload()}, wantErr: ErrBodyInvalid},
+		{name: "ChunkedDenied", req: chunked(false), wantErr: ErrStreamingPayloadDenied},
+		{name: "StreamingBody", req: hashed(false), opts: []Option{WithStreamingBody()}},
+		{name: "StreamingBodyTampered", req: hashed(true), opts: []Option{WithStreamingBody()}, wantErr: ErrBodyInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyRequest(tt.req, keyFn, false, append(tt.opts, WithBodyCanonicalization(BodyExact))...)
+			if err != tt.wantErr {
+				t.Fatalf("VerifyRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			// 验证通过后可以读取解码后的请求内容
+			if b, _ := ioutil.ReadAll(tt.req.Body); !bytes.Equal(b, data) {
+				t.Errorf("body length = %d, want %d", len(b), len(data))
+			}
+		})
+	}
+}
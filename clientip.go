@@ -0,0 +1,78 @@
+package ginaksk
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ErrSourceNotAllowed 来源IP不允许使用accessKey
+var ErrSourceNotAllowed = newStatusError(http.StatusForbidden, "来源IP不允许使用accesskey")
+
+// clientIP 返回客户端IP, 不能解析时返回nil
+func (o *options) clientIP(r *http.Request) net.IP {
+	ip := remoteIP(r)
+	if ip == nil || !o.trustedProxy(ip) {
+		return ip
+	}
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		ss := strings.Split(strings.Join(xff, ","), ",")
+		for i := len(ss) - 1; i >= 0; i-- {
+			p := net.ParseIP(strings.TrimSpace(ss[i]))
+			if p == nil {
+				// 无法解析的地址之前的内容不可信
+				return ip
+			}
+			ip = p
+			if !o.trustedProxy(p) {
+				return p
+			}
+		}
+		return ip
+	}
+	if p := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); p != nil {
+		return p
+	}
+	return ip
+}
+
+// trustedProxy 返回ip是否是可信代理
+func (o *options) trustedProxy(ip net.IP) bool {
+	for _, n := range o.trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteIP 返回直接连接的地址
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// allowSource 检查客户端IP是否在凭证允许的范围内
+func (v *validator) allowSource(r *http.Request, cred *Credential) error {
+	if len(cred.AllowedCIDRs) == 0 {
+		return nil
+	}
+	ip := v.clientIP(r)
+	if ip == nil {
+		return ErrSourceNotAllowed
+	}
+	for _, s := range cred.AllowedCIDRs {
+		n, err := parseCIDR(s)
+		if err != nil {
+			logger.Printf("accesskey: %s 的AllowedCIDRs无效: %s", cred.AccessKey, err)
+			continue
+		}
+		if n.Contains(ip) {
+			return nil
+		}
+	}
+	return ErrSourceNotAllowed
+}
@@ -0,0 +1,77 @@
+package ginaksk
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func Test_clientIP(t *testing.T) {
+	o := newOptions(WithTrustedProxies("10.0.0.0/8", "192.168.1.1"))
+	tests := []struct {
+		name   string
+		remote string
+		xff    string
+		realIP string
+		want   string
+	}{
+		{name: "Direct", remote: "203.0.113.5:1234", want: "203.0.113.5"},
+		{name: "SpoofedXFF", remote: "203.0.113.5:1234", xff: "198.51.100.1", want: "203.0.113.5"},
+		{name: "TrustedXFF", remote: "10.0.0.2:80", xff: "198.51.100.1", want: "198.51.100.1"},
+		{name: "ProxyChain", remote: "10.0.0.2:80", xff: "1.1.1.1, 198.51.100.1, 192.168.1.1", want: "198.51.100.1"},
+		{name: "MalformedXFF", remote: "10.0.0.2:80", xff: "198.51.100.1, bad", want: "10.0.0.2"},
+		{name: "RealIP", remote: "10.0.0.2:80", realIP: "198.51.100.2", want: "198.51.100.2"},
+		{name: "UntrustedRealIP", remote: "203.0.113.5:1234", realIP: "198.51.100.2", want: "203.0.113.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest("GET", "http://localhost/", nil)
+			r.RemoteAddr = tt.remote
+			if tt.xff != "" {
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if got := o.clientIP(r); got.String() != tt.want {
+				t.Errorf("clientIP() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllowedCIDRs(t *testing.T) {
+	t.Cleanup(cleanup)
+	v := &validator{
+		credFn: func(ak string) (*Credential, error) {
+			return &Credential{AccessKey: ak, SecretKey: "sk", AllowedCIDRs: []string{"198.51.100.0/24"}}, nil
+		},
+		options: newOptions(WithTrustedProxies("10.0.0.0/8")),
+	}
+	tests := []struct {
+		name    string
+		remote  string
+		xff     string
+		sk      string
+		wantErr error
+	}{
+		{name: "Allowed", remote: "198.51.100.7:1234", sk: "sk"},
+		{name: "AllowedViaProxy", remote: "10.0.0.2:80", xff: "198.51.100.7", sk: "sk"},
+		{name: "NotAllowed", remote: "203.0.113.5:1234", sk: "sk", wantErr: ErrSourceNotAllowed},
+		{name: "Spoofed", remote: "203.0.113.5:1234", xff: "198.51.100.7", sk: "sk", wantErr: ErrSourceNotAllowed},
+		{name: "InvalidSignature", remote: "203.0.113.5:1234", sk: "other", wantErr: ErrSignatureInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := generateRequest("ak", tt.sk)
+			r.RemoteAddr = tt.remote
+			if tt.xff != "" {
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if err := v.validRequest(&gin.Context{Request: r}); err != tt.wantErr {
+				t.Errorf("validRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
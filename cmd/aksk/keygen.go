@@ -0,0 +1,27 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/antlinker/ginaksk"
+)
+
+// keygen 生成一对accessKey和secretKey
+func keygen(args []string) error {
+	var alg algorithm
+	fs := flag.NewFlagSet("keygen", flag.ExitOnError)
+	prefix := fs.String("prefix", "", "accessKey的前缀")
+	length := fs.Int("length", 32, "secretKey的随机字节数")
+	alg.register(fs)
+	fs.Parse(args)
+	if err := alg.apply(); err != nil {
+		return err
+	}
+	ak, sk, err := ginaksk.NewKeyGenerator(*prefix, *length).Generate()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("accesskey: %s\nsecretkey: %s\n", ak, sk)
+	return nil
+}
@@ -0,0 +1,126 @@
+/*
+aksk 是ginaksk的命令行工具, 用于生成密钥、签名请求和校验请求
+
+	aksk keygen [-prefix AKPROD] [-length 32]
+	aksk sign -ak AK -sk SK [-X POST] [-d @body.json] [-curl] URL
+	aksk verify -sk SK [-skip-body] request.txt
+
+所有子命令都支持 -hash 和 -encoder 选择哈希算法和编码格式, 必须与服务端的配置一致
+*/
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/antlinker/ginaksk"
+)
+
+var hashFuncs = map[string]ginaksk.HashFunc{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+var encoders = map[string]ginaksk.Encoder{
+	"hex":       nil,
+	"base64":    &base64Encoder{enc: base64.StdEncoding},
+	"base64url": &base64Encoder{enc: base64.RawURLEncoding},
+}
+
+// base64Encoder base64编码格式
+type base64Encoder struct {
+	enc *base64.Encoding
+}
+
+func (b64 *base64Encoder) EncodeToString(b []byte) string {
+	return b64.enc.EncodeToString(b)
+}
+
+func (b64 *base64Encoder) DecodeString(s string) ([]byte, error) {
+	return b64.enc.DecodeString(s)
+}
+
+var canonicalizations = map[string]ginaksk.BodyCanonicalization{
+	"exact":   ginaksk.BodyExact,
+	"trimmed": ginaksk.BodyTrimmed,
+	"json":    ginaksk.BodyCanonicalJSON,
+}
+
+// algorithm 哈希算法、编码格式和请求内容规范化方式的命令行参数
+type algorithm struct {
+	hash      string
+	encoder   string
+	canonical string
+}
+
+func (a *algorithm) register(fs *flag.FlagSet) {
+	fs.StringVar(&a.hash, "hash", "sha256", "哈希算法: md5, sha1, sha256, sha512")
+	fs.StringVar(&a.encoder, "encoder", "hex", "编码格式: hex, base64, base64url")
+	fs.StringVar(&a.canonical, "canonical", "exact", "请求内容的规范化方式: exact, trimmed, json")
+}
+
+// canonicalization 返回请求内容的规范化方式
+func (a *algorithm) canonicalization() (ginaksk.BodyCanonicalization, error) {
+	m, ok := canonicalizations[a.canonical]
+	if !ok {
+		return 0, fmt.Errorf("不支持的规范化方式: %s", a.canonical)
+	}
+	return m, nil
+}
+
+// apply 设置ginaksk的哈希算法和编码格式
+func (a *algorithm) apply() error {
+	h, ok := hashFuncs[a.hash]
+	if !ok {
+		return fmt.Errorf("不支持的哈希算法: %s", a.hash)
+	}
+	enc, ok := encoders[a.encoder]
+	if !ok {
+		return fmt.Errorf("不支持的编码格式: %s", a.encoder)
+	}
+	ginaksk.SetHash(h)
+	ginaksk.SetEncoder(enc)
+	return nil
+}
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"keygen": {usage: "生成accessKey和secretKey", run: keygen},
+	"sign":   {usage: "签名请求, 输出头部或curl命令", run: sign},
+	"verify": {usage: "校验文件中的原始HTTP请求", run: verify},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "用法: aksk <command> [arguments]\n\n")
+	for _, name := range []string{"keygen", "sign", "verify"} {
+		fmt.Fprintf(os.Stderr, "\t%-8s %s\n", name, commands[name].usage)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "aksk %s: %s\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http/httputil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/antlinker/ginaksk"
+)
+
+// 子进程中运行main, 每次运行使用新的哈希算法和编码格式
+func TestMain(m *testing.M) {
+	if os.Getenv("AKSK_TEST_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// run 在子进程中运行aksk, 返回标准输出
+func run(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "AKSK_TEST_MAIN=1")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return stdout.String(), fmt.Errorf("%w: %s", err, stderr.String())
+	}
+	return stdout.String(), nil
+}
+
+// rawRequest 使用sign输出的头部拼接原始HTTP请求
+func rawRequest(headers, body string) []byte {
+	var b strings.Builder
+	b.WriteString("POST /orders HTTP/1.1\r\nHost: localhost\r\n")
+	for _, line := range strings.Split(strings.TrimSpace(headers), "\n") {
+		b.WriteString(line + "\r\n")
+	}
+	fmt.Fprintf(&b, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return []byte(b.String())
+}
+
+func TestSignVerify(t *testing.T) {
+	dir := t.TempDir()
+	body := `{"b": 1, "a": [1, 2]}`
+	tests := []struct {
+		name string
+		// alg 哈希算法、编码格式和规范化方式的参数, sign和verify相同
+		alg  []string
+		sign []string
+		body string
+	}{
+		{name: "Hex"},
+		{name: "Base64", alg: []string{"-hash", "sha512", "-encoder", "base64"}},
+		{name: "Base64URL", alg: []string{"-hash", "sha1", "-encoder", "base64url"}},
+		{name: "CanonicalJSON", alg: []string{"-canonical", "json"}, body: "{\"a\":[1,2],\n\"b\":1}"},
+		{name: "Trimmed", alg: []string{"-canonical", "trimmed"}, body: body + "\n"},
+		{name: "Strict", sign: []string{"-strict"}},
+		{name: "Digest", sign: []string{"-digest", "sha-256,sha-512"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append(append(append([]string{"sign", "-ak", "ak", "-sk", "sk", "-X", "post", "-d", body}, tt.alg...), tt.sign...), "http://localhost/orders")
+			headers, err := run(t, args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sent := body
+			if tt.body != "" {
+				sent = tt.body
+			}
+			verify := func(b []byte) error {
+				name := filepath.Join(dir, tt.name+".txt")
+				if err := ioutil.WriteFile(name, b, 0600); err != nil {
+					t.Fatal(err)
+				}
+				out, err := run(t, append(append([]string{"verify", "-ak", "ak", "-sk", "sk"}, tt.alg...), name)...)
+				if err == nil && out != "OK\n" {
+					t.Errorf("verify output = %q, want OK", out)
+				}
+				return err
+			}
+			if err := verify(rawRequest(headers, sent)); err != nil {
+				t.Errorf("verify error = %v", err)
+			}
+			if err := verify(rawRequest(headers, strings.Replace(sent, "1", "3", 1))); err == nil {
+				t.Error("verify tampered body succeeded")
+			}
+			if err := verify(rawRequest(strings.Replace(headers, "x-auth-accesskey: ak", "x-auth-accesskey: other", 1), sent)); err == nil {
+				t.Error("verify with other accesskey succeeded")
+			}
+		})
+	}
+}
+
+func TestSignCurl(t *testing.T) {
+	out, err := run(t, "sign", "-ak", "ak", "-sk", "sk", "-X", "put", "-d", "it's", "-curl", "http://localhost/orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"curl -X PUT ", "-H 'x-auth-accesskey: ak'", "-H 'x-auth-body-hash: ", `--data-binary 'it'\''s'`, " 'http://localhost/orders'\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("curl command %q does not contain %q", out, want)
+		}
+	}
+}
+
+func TestVerifyStreaming(t *testing.T) {
+	dir := t.TempDir()
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	f, err := ginaksk.NewStreamingRequestFunc("ak", "sk", 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := f(context.TODO(), "PUT", "http://localhost/upload", bytes.NewReader(data))
+	raw, err := httputil.DumpRequestOut(req, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		raw     []byte
+		wantErr bool
+	}{
+		{name: "Ok", raw: raw},
+		{name: "TamperedChunk", raw: bytes.Replace(raw, []byte("0123456789"), []byte("0123456788"), 1), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(dir, tt.name+".txt")
+			if err := ioutil.WriteFile(name, tt.raw, 0600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := run(t, "verify", "-sk", "sk", name); (err != nil) != tt.wantErr {
+				t.Errorf("verify error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/antlinker/ginaksk"
+)
+
+// sign 签名请求, 输出签名头部或curl命令
+func sign(args []string) error {
+	var alg algorithm
+	fs := flag.NewFlagSet("sign", flag.ExitOnError)
+	ak := fs.String("ak", "", "accessKey")
+	sk := fs.String("sk", "", "secretKey")
+	method := fs.String("X", "GET", "请求方法")
+	data := fs.String("d", "", "请求内容, @file读取文件, @-读取标准输入")
+	curl := fs.Bool("curl", false, "输出curl命令")
+	strict := fs.Bool("strict", false, "总是发送x-auth-body-hash")
+	digest := fs.String("digest", "", "发送Content-Digest和x-auth-body-hash: CONTENT-DIGEST, 逗号分隔的摘要算法, 如sha-256,sha-512")
+	unsigned := fs.Bool("unsigned", false, "不签名请求内容, 发送x-auth-body-hash: UNSIGNED-PAYLOAD")
+	contentType := fs.String("content-type", "", "请求的Content-Type, -canonical json时默认为application/json")
+	alg.register(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("需要指定请求URL")
+	}
+	url := fs.Arg(0)
+	if err := alg.apply(); err != nil {
+		return err
+	}
+	body, err := readData(*data)
+	if err != nil {
+		return err
+	}
+	m, err := alg.canonicalization()
+	if err != nil {
+		return err
+	}
+	opts := []ginaksk.RequestOption{ginaksk.WithRequestBodyCanonicalization(m), ginaksk.WithContentType(*contentType)}
+	if *strict {
+		opts = append(opts, ginaksk.WithRequestStrictBodyHash())
+	}
+	if *unsigned {
+		opts = append(opts, ginaksk.WithRequestUnsignedPayload())
+	}
+	if *digest != "" {
+		opts = append(opts, ginaksk.WithContentDigest(strings.Split(*digest, ",")...))
+	}
+	fn, err := ginaksk.NewRequestFunc(*ak, *sk, opts...)
+	if err != nil {
+		return err
+	}
+	req, err := fn(context.Background(), strings.ToUpper(*method), url, body)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if !*curl {
+		for _, k := range keys {
+			fmt.Printf("%s: %s\n", strings.ToLower(k), req.Header.Get(k))
+		}
+		return nil
+	}
+	ss := []string{"curl", "-X", req.Method}
+	for _, k := range keys {
+		ss = append(ss, "-H", quote(strings.ToLower(k)+": "+req.Header.Get(k)))
+	}
+	if len(body) > 0 {
+		if strings.HasPrefix(*data, "@") && *data != "@-" {
+			ss = append(ss, "--data-binary", quote(*data))
+		} else {
+			ss = append(ss, "--data-binary", quote(string(body)))
+		}
+	}
+	ss = append(ss, quote(url))
+	fmt.Println(strings.Join(ss, " "))
+	return nil
+}
+
+// readData 读取-d参数指定的请求内容
+func readData(s string) ([]byte, error) {
+	switch {
+	case s == "@-":
+		return ioutil.ReadAll(os.Stdin)
+	case strings.HasPrefix(s, "@"):
+		return ioutil.ReadFile(s[1:])
+	}
+	return []byte(s), nil
+}
+
+// quote 转义为shell的单引号字符串
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/antlinker/ginaksk"
+)
+
+// verify 校验文件中的原始HTTP请求
+func verify(args []string) error {
+	var alg algorithm
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	ak := fs.String("ak", "", "期望的accessKey, 为空时不检查")
+	sk := fs.String("sk", "", "secretKey")
+	skipBody := fs.Bool("skip-body", false, "跳过检查body的hash值")
+	debug := fs.Bool("debug", false, "签名无效时输出诊断信息")
+	strict := fs.Bool("strict", false, "严格检查请求内容的哈希值")
+	alg.register(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("需要指定原始HTTP请求文件, -表示标准输入")
+	}
+	if *sk == "" {
+		return errors.New("需要指定secretKey")
+	}
+	if err := alg.apply(); err != nil {
+		return err
+	}
+	m, err := alg.canonicalization()
+	if err != nil {
+		return err
+	}
+	// VerifyRequest在返回前读取并验证完整的请求内容, 因此接受分块签名和尾部哈希值
+	opts := []ginaksk.Option{ginaksk.WithBodyCanonicalization(m), ginaksk.WithStreamingPayload(), ginaksk.WithTrailerPayload()}
+	if *strict {
+		opts = append(opts, ginaksk.WithStrictBodyHash())
+	}
+	if *debug {
+		ginaksk.SetLogger(log.New(os.Stderr, "", 0))
+		opts = append(opts, ginaksk.WithDebug())
+	}
+	f := os.Stdin
+	if name := fs.Arg(0); name != "-" {
+		var err error
+		if f, err = os.Open(name); err != nil {
+			return err
+		}
+		defer f.Close()
+	}
+	req, err := http.ReadRequest(bufio.NewReader(f))
+	if err != nil {
+		return fmt.Errorf("解析HTTP请求发生错误: %w", err)
+	}
+	keyFn := func(accessKey string) string {
+		if *ak != "" && accessKey != *ak {
+			return ""
+		}
+		return *sk
+	}
+	if err := ginaksk.VerifyRequest(req, keyFn, *skipBody, opts...); err != nil {
+		return err
+	}
+	fmt.Println("OK")
+	return nil
+}
@@ -0,0 +1,74 @@
+package ginaksk
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrTooManyConcurrent 同时进行的请求过多
+var ErrTooManyConcurrent = newStatusError(http.StatusTooManyRequests, "同时进行的请求过多")
+
+// ConcurrencyLimiter 按照验证通过的accessKey限制同时进行的请求数, 临时会话凭证计入签发者的accessKey
+type ConcurrencyLimiter struct {
+	mu       sync.Mutex
+	limit    int
+	inflight map[string]int
+}
+
+// NewConcurrencyLimiter 返回一个ConcurrencyLimiter, limit为默认的限制, 凭证的MaxConcurrent大于0时使用凭证的限制
+func NewConcurrencyLimiter(limit int) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{limit: limit, inflight: make(map[string]int)}
+}
+
+// InFlight 返回accessKey正在进行的请求数
+func (l *ConcurrencyLimiter) InFlight(accessKey string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inflight[accessKey]
+}
+
+// acquire 占用一个请求数, 达到限制时返回false
+func (l *ConcurrencyLimiter) acquire(accessKey string, limit int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inflight[accessKey] >= limit {
+		return false
+	}
+	l.inflight[accessKey]++
+	return true
+}
+
+// release 释放一个请求数
+func (l *ConcurrencyLimiter) release(accessKey string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inflight[accessKey]--; l.inflight[accessKey] <= 0 {
+		delete(l.inflight, accessKey)
+	}
+}
+
+// Middleware 返回一个限制同时进行的请求数的gin中间件, 必须在Validate或New返回的中间件之后使用;
+// 后续的处理函数返回、终止或者panic时释放占用的请求数, 达到限制时返回ErrTooManyConcurrent
+func (l *ConcurrencyLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cred, ak := limitKey(c)
+		if cred == nil {
+			return
+		}
+		limit := l.limit
+		if cred.MaxConcurrent > 0 {
+			limit = cred.MaxConcurrent
+		}
+		if limit <= 0 {
+			return
+		}
+		if !l.acquire(ak, limit) {
+			abort(c, ErrTooManyConcurrent)
+			return
+		}
+		defer l.release(ak)
+		c.Next()
+	}
+}
@@ -0,0 +1,63 @@
+package ginaksk
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	t.Cleanup(cleanup)
+	gin.SetMode(gin.TestMode)
+	credFn := func(ak string) (*Credential, error) {
+		return &Credential{AccessKey: ak, SecretKey: "sk", MaxConcurrent: 1}, nil
+	}
+	l := NewConcurrencyLimiter(10)
+	entered := make(chan struct{})
+	unblock := make(chan struct{})
+	e := gin.New()
+	e.Use(gin.CustomRecovery(func(c *gin.Context, err interface{}) {
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}))
+	e.Use(New(credFn), l.Middleware())
+	e.GET("/slow", func(c *gin.Context) {
+		entered <- struct{}{}
+		<-unblock
+		c.Status(http.StatusOK)
+	})
+	e.GET("/panic", func(c *gin.Context) {
+		panic("handler panic")
+	})
+	serve := func(ak, path string) int {
+		f, _ := NewRequestFunc(ak, "sk")
+		req, _ := f(context.TODO(), "GET", `http://localhost`+path, nil)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	done := make(chan int)
+	go func() {
+		done <- serve("partner", "/slow")
+	}()
+	<-entered
+	if code := serve("partner", "/slow"); code != http.StatusTooManyRequests {
+		t.Errorf("concurrent request status = %d, want %d", code, http.StatusTooManyRequests)
+	}
+	if n := l.InFlight("partner"); n != 1 {
+		t.Errorf("InFlight() = %d, want 1", n)
+	}
+	close(unblock)
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("first request status = %d, want %d", code, http.StatusOK)
+	}
+	if code := serve("partner", "/panic"); code != http.StatusInternalServerError {
+		t.Errorf("panic request status = %d, want %d", code, http.StatusInternalServerError)
+	}
+	if n := l.InFlight("partner"); n != 0 {
+		t.Errorf("InFlight() after panic = %d, want 0", n)
+	}
+}
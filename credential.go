@@ -0,0 +1,80 @@
+package ginaksk
+
+import "github.com/gin-gonic/gin"
+
+// Credential accessKey对应的凭证
+type Credential struct {
+	// AccessKey 访问key
+	AccessKey string
+	// SecretKey 签名使用的secretKey
+	SecretKey string
+	// Scopes 权限范围, 如orders:read, orders:*匹配orders下的所有权限, *匹配所有权限
+	Scopes []string
+	// Policy 访问策略, 为nil时不检查
+	Policy *Policy
+	// AllowedCIDRs 允许使用accessKey的来源IP或CIDR, 为空时不限制
+	AllowedCIDRs []string
+	// RateLimit 请求速率限制, 为nil时使用RateLimiter的默认限制
+	RateLimit *Limit
+	// Quota 请求配额, 为nil时使用QuotaCounter的默认配额
+	Quota *Quota
+	// MaxConcurrent 同时进行的请求数限制, 小于等于0时使用ConcurrencyLimiter的默认限制
+	MaxConcurrent int
+	// MaxBodySize 请求内容的大小限制, 小于等于0时使用WithMaxBodySize的限制
+	MaxBodySize int64
+	// AllowUnsignedPayload 允许使用未签名的请求内容, 即x-auth-body-hash为UNSIGNED-PAYLOAD
+	AllowUnsignedPayload bool
+	// Metadata 凭证的其他信息, 如所属的租户
+	Metadata map[string]string
+}
+
+// CredentialFunc 查询accessKey对应的凭证, 凭证不存在时返回nil
+type CredentialFunc func(accessKey string) (*Credential, error)
+
+// credentialFunc 将KeyFunc转换为CredentialFunc
+func (fn KeyFunc) credentialFunc() CredentialFunc {
+	return func(accessKey string) (*Credential, error) {
+		sk := fn(accessKey)
+		if sk == "" {
+			return nil, nil
+		}
+		return &Credential{AccessKey: accessKey, SecretKey: sk}, nil
+	}
+}
+
+// errorHandlerKey gin.Context中保存错误处理函数的key
+const errorHandlerKey = "ginaksk.errorHandler"
+
+// credential 返回验证通过的凭证, 不包含SecretKey
+func credential(c *gin.Context) *Credential {
+	if p, ok := GetPrincipal(c); ok {
+		return p.Credential
+	}
+	return nil
+}
+
+// limitKey 返回验证通过的凭证和统计限制使用的accessKey, 临时会话凭证计入签发者的accessKey
+func limitKey(c *gin.Context) (*Credential, string) {
+	p, ok := GetPrincipal(c)
+	if !ok {
+		return nil, ""
+	}
+	if p.ParentAccessKey != "" {
+		return p.Credential, p.ParentAccessKey
+	}
+	return p.Credential, p.AccessKey
+}
+
+// abort 使用中间件配置的错误处理函数终止请求
+func abort(c *gin.Context, err error) {
+	fn := handleError
+	if v, ok := c.Get(errorHandlerKey); ok {
+		if h, ok := v.(ErrorHandler); ok && h != nil {
+			fn = h
+		}
+	}
+	fn(c, err)
+	if !c.IsAborted() {
+		c.Abort()
+	}
+}
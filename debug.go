@@ -0,0 +1,118 @@
+package ginaksk
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// headerDebug 调试模式下返回签名诊断信息的响应头部
+	headerDebug = `x-auth-debug`
+	// debugBodyLimit 诊断时最多读取的请求内容, 超过时不诊断请求内容
+	debugBodyLimit = 64 << 10
+)
+
+// signatureDebug 签名验证失败的诊断信息
+type signatureDebug struct {
+	// StringToSign 服务端计算的待签名字符串
+	StringToSign string `json:"string_to_sign"`
+	// Hash 服务端使用的哈希算法
+	Hash string `json:"hash"`
+	// Encoder 服务端使用的编码格式
+	Encoder string `json:"encoder"`
+	// Mismatches 可能与客户端不一致的输入
+	Mismatches []string `json:"mismatches"`
+}
+
+// explainSignature 诊断签名不一致的原因, 输出到日志, 并对允许的accessKey设置x-auth-debug响应头部
+func (v *validator) explainSignature(c *gin.Context, sk, sign string, h signedHeaders) {
+	d := &signatureDebug{
+		StringToSign: stringToSign(h.elems()...),
+		Hash:         fmt.Sprintf("%T", hashFunc()),
+		Encoder:      fmt.Sprintf("%T", encoder),
+	}
+	d.Mismatches = v.explainMismatches(c, sk, sign, h)
+	b, _ := json.Marshal(d)
+	logger.Printf("签名验证失败 accesskey: %s, 诊断信息: %s", h.accessKey, b)
+	if v.debugKeys[h.accessKey] && c.Writer != nil {
+		c.Header(headerDebug, string(b))
+	}
+}
+
+// explainMismatches 尝试常见的客户端错误, 返回可能不一致的输入
+func (v *validator) explainMismatches(c *gin.Context, sk, sign string, h signedHeaders) []string {
+	mac, err := encoder.DecodeString(sign)
+	if err != nil {
+		return []string{fmt.Sprintf("%s无法使用%T解码, 客户端可能使用了其他编码格式", headerSignature, encoder)}
+	}
+	if size := hashFunc().Size(); len(mac) != size {
+		return []string{fmt.Sprintf("%s的长度为%d字节, %T的长度为%d字节, 客户端可能使用了其他哈希算法", headerSignature, len(mac), hashFunc(), size)}
+	}
+	var ss []string
+	body, ok := v.debugBody(c)
+	if ok && !payloadMarker(h.bodyHash) && validBytes(body, h.bodyHash) != nil {
+		ss = append(ss, fmt.Sprintf("%s与请求内容的哈希值不一致", headerBodyHash))
+	}
+	key := []byte(sk)
+	// with 返回修改了h中一个头部后计算的HMAC
+	with := func(f func(h *signedHeaders)) []byte {
+		h := h
+		f(&h)
+		return hmacSum(key, h.elems()...)
+	}
+	switch {
+	case h.bodyHash == "" && len(body) > 0 && hmac.Equal(mac, with(func(h *signedHeaders) { h.bodyHash = encoder.EncodeToString(hashSum(body)) })):
+		ss = append(ss, fmt.Sprintf("请求缺少%s, 客户端签名时包含了请求内容的哈希值", headerBodyHash))
+	case h.randomStr != "" && hmac.Equal(mac, with(func(h *signedHeaders) { h.randomStr = "" })):
+		ss = append(ss, fmt.Sprintf("客户端签名时未包含%s", headerRandomStr))
+	case h.bodyHash != "" && hmac.Equal(mac, with(func(h *signedHeaders) { h.bodyHash = "" })):
+		ss = append(ss, fmt.Sprintf("客户端签名时未包含%s", headerBodyHash))
+	case hmac.Equal(mac, hmacRaw(key, strings.Join(h.elems(), ""))):
+		ss = append(ss, "客户端拼接待签名字符串前未按照字典序排序")
+	default:
+		if b, err := encoder.DecodeString(sk); err == nil && hmac.Equal(mac, hmacSum(b, h.elems()...)) {
+			ss = append(ss, "客户端使用了解码后的secretKey作为HMAC密钥")
+		}
+	}
+	if len(ss) == 0 {
+		ss = append(ss, "无法确定不一致的输入, 请对比string_to_sign和客户端的待签名字符串, 并确认secretKey一致")
+	}
+	return ss
+}
+
+// debugBody 读取最多debugBodyLimit字节的请求内容并规范化, 请求内容过大或读取失败时ok为false;
+// 读取的内容放回请求内容之前
+func (v *validator) debugBody(c *gin.Context) (body []byte, ok bool) {
+	r := c.Request
+	if r == nil || r.Body == nil {
+		return nil, true
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(r.Body, debugBodyLimit+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(b), r.Body), r.Body}
+	if err != nil || len(b) > debugBodyLimit {
+		return nil, false
+	}
+	if b, err = v.canonical.canonicalize(b, r.Header.Get("Content-Type")); err != nil {
+		return nil, false
+	}
+	return b, true
+}
+
+// payloadMarker 返回x-auth-body-hash是否为空或者不是请求内容的哈希值
+func payloadMarker(bodyhash string) bool {
+	switch bodyhash {
+	case "", UnsignedPayload, StreamingPayload, TrailerPayload, ContentDigestPayload:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package ginaksk
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestValidateWithDebug(t *testing.T) {
+	t.Cleanup(cleanup)
+	keyFn := func(string) string {
+		return "250cf8b51c773f3f8dc8b4be867a9a02"
+	}
+	h := Validate(keyFn, false, nil, WithDebug("debug"))
+	unsorted := func(ak string) *http.Request {
+		req := generateRequest(ak, "250cf8b51c773f3f8dc8b4be867a9a02")
+		s := req.Header.Get(headerAccessKey) + req.Header.Get(headerTimestamp) + req.Header.Get(headerRandomStr) + req.Header.Get(headerBodyHash)
+		req.Header.Set(headerSignature, encoder.EncodeToString(hmacRaw([]byte("250cf8b51c773f3f8dc8b4be867a9a02"), s)))
+		return req
+	}
+	tests := []struct {
+		name      string
+		req       *http.Request
+		wantDebug string
+	}{
+		{
+			name:      "Unsorted",
+			req:       unsorted("debug"),
+			wantDebug: "字典序",
+		},
+		{
+			name:      "WithoutBodyHash",
+			req:       generateRequestWithHeader("debug", "250cf8b51c773f3f8dc8b4be867a9a02", headerBodyHash, ""),
+			wantDebug: headerBodyHash,
+		},
+		{
+			name:      "InvalidEncoding",
+			req:       generateRequestWithHeader("debug", "250cf8b51c773f3f8dc8b4be867a9a02", headerSignature, "not-hex"),
+			wantDebug: "编码格式",
+		},
+		{
+			name: "NotAllowed",
+			req:  unsorted("other"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = tt.req
+			h(c)
+			if !c.IsAborted() {
+				t.Fatal("request not aborted")
+			}
+			s := w.Header().Get(headerDebug)
+			if tt.wantDebug == "" {
+				if s != "" {
+					t.Errorf("%s = %s, want empty", headerDebug, s)
+				}
+				return
+			}
+			var d signatureDebug
+			if err := json.Unmarshal([]byte(s), &d); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(strings.Join(d.Mismatches, ";"), tt.wantDebug) {
+				t.Errorf("Mismatches = %v, want %s", d.Mismatches, tt.wantDebug)
+			}
+			t.Logf("%+v", d)
+		})
+	}
+}
+
+// countingReader 记录读取的字节数
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += n
+	return n, err
+}
+
+func TestDebugBodyLimit(t *testing.T) {
+	t.Cleanup(cleanup)
+	h := New(func(ak string) (*Credential, error) {
+		return &Credential{AccessKey: ak, SecretKey: "sk"}, nil
+	}, WithDebug("debug"), WithStreamingBody())
+	for _, size := range []int{10, debugBodyLimit + 1, 10 * debugBodyLimit} {
+		body := &countingReader{r: bytes.NewReader(bytes.Repeat([]byte("a"), size))}
+		req := generateRequestWithHeader("debug", "sk", headerSignature, encoder.EncodeToString(hashSum([]byte("x"))))
+		req.Body = ioutil.NopCloser(body)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = req
+		h(c)
+		if !c.IsAborted() {
+			t.Fatal("request not aborted")
+		}
+		if body.n > debugBodyLimit+1 {
+			t.Errorf("size %d: read %d bytes, want at most %d", size, body.n, debugBodyLimit+1)
+		}
+		var d signatureDebug
+		if err := json.Unmarshal([]byte(w.Header().Get(headerDebug)), &d); err != nil {
+			t.Fatal(err)
+		}
+		// 只有读取了完整的请求内容时才比较哈希值
+		got := strings.Contains(strings.Join(d.Mismatches, ";"), "哈希值不一致")
+		if want := size <= debugBodyLimit; got != want {
+			t.Errorf("size %d: Mismatches = %v", size, d.Mismatches)
+		}
+	}
+}
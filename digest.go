@@ -0,0 +1,188 @@
+package ginaksk
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContentDigestPayload x-auth-body-hash的取值, 表示使用RFC 9530的摘要字段验证请求内容, 摘要字段的值参与签名;
+// 没有该取值时中间件忽略代理等添加的摘要字段
+const ContentDigestPayload = "CONTENT-DIGEST"
+
+const (
+	// headerContentDigest RFC 9530中请求内容的摘要
+	headerContentDigest = `Content-Digest`
+	// headerReprDigest RFC 9530中表示的摘要, 没有Content-Encoding时与请求内容的摘要相同
+	headerReprDigest = `Repr-Digest`
+)
+
+var (
+	// ErrDigestInvalid 摘要字段的格式无效
+	ErrDigestInvalid = newError("请求内容摘要无效")
+	// ErrDigestUnsupported 摘要字段中没有支持的算法
+	ErrDigestUnsupported = newError("不支持请求内容摘要的算法")
+)
+
+// digestAlgorithms 支持的摘要算法, RFC 9530中已废弃的md5, sha等算法不支持
+var digestAlgorithms = map[string]func() hash.Hash{
+	"sha-256": sha256.New,
+	"sha-512": sha512.New,
+}
+
+// parseDigest 解析RFC 9530的摘要字段, 字段为RFC 8941的字典, 如sha-256=:base64:, sha-512=:base64:;
+// 忽略成员的参数, 重复的算法使用最后一个
+func parseDigest(s string) (map[string][]byte, error) {
+	m := make(map[string][]byte)
+	for _, member := range strings.Split(s, ",") {
+		member = strings.Trim(member, " \t")
+		if i := strings.IndexByte(member, ';'); i >= 0 {
+			member = member[:i]
+		}
+		i := strings.IndexByte(member, '=')
+		if i <= 0 || !validDigestKey(member[:i]) {
+			return nil, ErrDigestInvalid
+		}
+		v := member[i+1:]
+		if len(v) < 2 || v[0] != ':' || v[len(v)-1] != ':' {
+			return nil, ErrDigestInvalid
+		}
+		b, err := base64.StdEncoding.DecodeString(v[1 : len(v)-1])
+		if err != nil {
+			return nil, ErrDigestInvalid
+		}
+		m[member[:i]] = b
+	}
+	return m, nil
+}
+
+// validDigestKey 返回s是否是RFC 8941字典的键
+func validDigestKey(s string) bool {
+	for i, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r == '*':
+		case i > 0 && (r >= '0' && r <= '9' || r == '_' || r == '-' || r == '.'):
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
+
+// formatDigest 返回使用algs计算的b的摘要字段, 不支持的算法返回错误
+func formatDigest(b []byte, algs []string) (string, error) {
+	ss := make([]string, 0, len(algs))
+	for _, alg := range algs {
+		fn, ok := digestAlgorithms[alg]
+		if !ok {
+			return "", fmt.Errorf("不支持的摘要算法: %s", alg)
+		}
+		h := fn()
+		h.Write(b)
+		ss = append(ss, alg+"=:"+base64.StdEncoding.EncodeToString(h.Sum(nil))+":")
+	}
+	return strings.Join(ss, ", "), nil
+}
+
+// digester 计算并比较请求头部中声明的摘要
+type digester struct {
+	hashes   []hash.Hash
+	expected [][]byte
+}
+
+// digestFields 返回请求的Content-Digest和Repr-Digest字段的值, 同名的多个字段使用", "拼接;
+// Repr-Digest只在没有Content-Encoding时使用, 这些值参与签名
+func digestFields(r *http.Request) []string {
+	names := []string{headerContentDigest}
+	if enc := r.Header.Get("Content-Encoding"); enc == "" || strings.EqualFold(enc, "identity") {
+		names = append(names, headerReprDigest)
+	}
+	var fields []string
+	for _, name := range names {
+		if s := strings.Join(r.Header.Values(name), ", "); s != "" {
+			fields = append(fields, s)
+		}
+	}
+	return fields
+}
+
+// newDigester 解析digestFields返回的摘要字段, 摘要字段中没有支持的算法时返回ErrDigestUnsupported
+func newDigester(fields []string) (*digester, error) {
+	d := &digester{}
+	for _, s := range fields {
+		m, err := parseDigest(s)
+		if err != nil {
+			return nil, err
+		}
+		algs := make([]string, 0, len(m))
+		for alg := range m {
+			algs = append(algs, alg)
+		}
+		sort.Strings(algs)
+		for _, alg := range algs {
+			if fn, ok := digestAlgorithms[alg]; ok {
+				d.hashes = append(d.hashes, fn())
+				d.expected = append(d.expected, m[alg])
+			}
+		}
+	}
+	if len(d.hashes) == 0 {
+		return nil, ErrDigestUnsupported
+	}
+	return d, nil
+}
+
+// Write 计算摘要
+func (d *digester) Write(p []byte) (int, error) {
+	for _, h := range d.hashes {
+		h.Write(p)
+	}
+	return len(p), nil
+}
+
+// verify 比较所有支持的摘要, 不一致时返回ErrBodyInvalid
+func (d *digester) verify() error {
+	for i, h := range d.hashes {
+		if !bytes.Equal(h.Sum(nil), d.expected[i]) {
+			return ErrBodyInvalid
+		}
+	}
+	return nil
+}
+
+// verifyDigest 按照请求摘要验证请求内容, 摘要按照原始字节计算, 不使用WithBodyCanonicalization
+func (v *validator) verifyDigest(c *gin.Context, d *digester) error {
+	switch {
+	case v.streaming:
+		if c.Request.Body == nil {
+			c.Request.Body = http.NoBody
+		}
+		b := newHashingBody(c.Request.Body, "")
+		b.digest = d
+		if v.strict {
+			b.length = c.Request.ContentLength
+		}
+		c.Request.Body = b
+		c.Set(bodyKey, b)
+		return nil
+	case v.spoolThreshold > 0 && isMultipart(c.GetHeader("Content-Type")):
+		return v.spoolBody(c, "", d)
+	}
+	b, err := readBody(c)
+	if err != nil {
+		return err
+	}
+	if v.strict && c.Request.ContentLength >= 0 && int64(len(b)) != c.Request.ContentLength {
+		return ErrContentLengthMismatch
+	}
+	d.Write(b)
+	return d.verify()
+}
@@ -0,0 +1,189 @@
+package ginaksk
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseDigest(t *testing.T) {
+	sum := sha256.Sum256([]byte("data"))
+	b64 := base64.StdEncoding.EncodeToString(sum[:])
+	tests := []struct {
+		name    string
+		in      string
+		want    map[string][]byte
+		wantErr bool
+	}{
+		{name: "Single", in: "sha-256=:" + b64 + ":", want: map[string][]byte{"sha-256": sum[:]}},
+		{name: "Multiple", in: "md5=:AAAA:,\tsha-256=:" + b64 + ":;note=1 , unixsum=:AA==:", want: map[string][]byte{
+			"md5": {0, 0, 0}, "sha-256": sum[:], "unixsum": {0},
+		}},
+		{name: "Duplicate", in: "sha-256=:AAAA:, sha-256=:" + b64 + ":", want: map[string][]byte{"sha-256": sum[:]}},
+		{name: "NotByteSequence", in: "sha-256=" + b64, wantErr: true},
+		{name: "InvalidBase64", in: "sha-256=:!!:", wantErr: true},
+		{name: "BareKey", in: "sha-256", wantErr: true},
+		{name: "UppercaseKey", in: "SHA-256=:" + b64 + ":", wantErr: true},
+		{name: "TrailingComma", in: "sha-256=:" + b64 + ":,", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDigest(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseDigest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseDigest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContentDigest(t *testing.T) {
+	t.Cleanup(cleanup)
+	gin.SetMode(gin.TestMode)
+	credFn := func(ak string) (*Credential, error) {
+		return &Credential{AccessKey: ak, SecretKey: "sk"}, nil
+	}
+	body := []byte(`{"param":"a"}`)
+	request := func(opts ...RequestOption) *http.Request {
+		f, _ := NewRequestFunc("ak", "sk", opts...)
+		req, _ := f(context.TODO(), "POST", `http://localhost/e`, body)
+		return req
+	}
+	// 其他语言的客户端发送Repr-Digest, 签名包含摘要字段的值
+	external := func(field, digest string, header http.Header) *http.Request {
+		req, _ := http.NewRequest("POST", `http://localhost/e`, bytes.NewReader(body))
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(headerAccessKey, "ak")
+		req.Header.Set(headerTimestamp, ts)
+		req.Header.Set(headerRandomStr, "random")
+		req.Header.Set(headerBodyHash, ContentDigestPayload)
+		req.Header.Set(field, digest)
+		for k := range header {
+			req.Header.Set(k, header.Get(k))
+		}
+		req.Header.Set(headerSignature, encoder.EncodeToString(hmacSum([]byte("sk"), "ak", ts, "random", ContentDigestPayload, digest)))
+		return req
+	}
+	reprDigest, _ := formatDigest(body, []string{"sha-256"})
+	tests := []struct {
+		name    string
+		req     func() *http.Request
+		want    int
+		wantErr error
+		// strictErr 严格模式下的错误, 为nil时与wantErr相同
+		strictErr error
+	}{
+		{name: "SHA256", req: func() *http.Request { return request(WithContentDigest()) }, want: http.StatusOK},
+		{name: "SHA512", req: func() *http.Request { return request(WithContentDigest("sha-512", "sha-256")) }, want: http.StatusOK},
+		{name: "Tampered", req: func() *http.Request {
+			req := request(WithContentDigest())
+			req.Body = ioutil.NopCloser(bytes.NewReader([]byte(`{"param":"b"}`)))
+			return req
+		}, want: http.StatusUnauthorized, wantErr: ErrBodyInvalid},
+		{name: "Stripped", req: func() *http.Request {
+			req := request(WithContentDigest())
+			req.Header.Del(headerContentDigest)
+			return req
+		}, want: http.StatusUnauthorized, wantErr: ErrBodyHashEmpty},
+		{name: "ReprDigest", req: func() *http.Request { return external(headerReprDigest, reprDigest, nil) }, want: http.StatusOK},
+		{name: "ReprDigestEncoded", req: func() *http.Request {
+			return external(headerReprDigest, reprDigest, http.Header{"Content-Encoding": {"gzip"}})
+		}, want: http.StatusUnauthorized, wantErr: ErrBodyHashEmpty},
+		{name: "Unsupported", req: func() *http.Request { return external(headerContentDigest, "md5=:AAAA:", nil) }, want: http.StatusUnauthorized, wantErr: ErrDigestUnsupported},
+		{name: "Invalid", req: func() *http.Request { return external(headerContentDigest, "sha-256=abc", nil) }, want: http.StatusUnauthorized, wantErr: ErrDigestInvalid},
+		// 代理添加的摘要字段不影响x-auth-body-hash
+		{name: "ProxyAdded", req: func() *http.Request {
+			req := request()
+			req.Header.Set(headerContentDigest, "sha-256=:AAAA:")
+			return req
+		}, want: http.StatusOK},
+	}
+	modes := map[string][]Option{
+		"Default":   nil,
+		"Strict":    {WithStrictBodyHash()},
+		"Streaming": {WithStreamingBody()},
+	}
+	for mode, opts := range modes {
+		var gotErr error
+		e := gin.New()
+		e.Use(New(credFn, append(opts, WithErrorHandler(func(c *gin.Context, err error) {
+			gotErr = err
+			handleError(c, err)
+		}))...))
+		e.POST("/e", func(c *gin.Context) {
+			ioutil.ReadAll(c.Request.Body)
+		})
+		for _, tt := range tests {
+			t.Run(mode+tt.name, func(t *testing.T) {
+				gotErr = nil
+				w := httptest.NewRecorder()
+				e.ServeHTTP(w, tt.req())
+				wantErr := tt.wantErr
+				if mode == "Strict" && tt.strictErr != nil {
+					wantErr = tt.strictErr
+				}
+				if w.Code != tt.want || gotErr != wantErr {
+					t.Errorf("status = %d, error = %v, want %d, %v", w.Code, gotErr, tt.want, wantErr)
+				}
+			})
+		}
+	}
+}
+
+func TestProxyAddedDigest(t *testing.T) {
+	t.Cleanup(cleanup)
+	gin.SetMode(gin.TestMode)
+	credFn := func(ak string) (*Credential, error) {
+		return &Credential{AccessKey: ak, SecretKey: "sk"}, nil
+	}
+	// 没有使用WithContentDigest的客户端, 代理添加摘要字段
+	request := func(body []byte, digest string) *http.Request {
+		f, _ := NewRequestFunc("ak", "sk")
+		req, _ := f(context.TODO(), "POST", `http://localhost/e`, body)
+		req.Header.Set(headerContentDigest, digest)
+		return req
+	}
+	// 客户端签名了不支持的摘要算法
+	signed := func(digest string) *http.Request {
+		req, _ := http.NewRequest("POST", `http://localhost/e`, nil)
+		req.Header.Set(headerContentDigest, digest)
+		o, _ := newRequestOptions("ak", "sk", nil)
+		o.sign(req, "ak", "sk", ContentDigestPayload)
+		return req
+	}
+	tests := []struct {
+		name     string
+		req      *http.Request
+		skipBody bool
+	}{
+		{name: "EmptyBody", req: request(nil, "sha-256=:AAAA:")},
+		{name: "EmptyBodyMD5", req: request(nil, "md5=:AAAA:")},
+		{name: "Invalid", req: request([]byte(`{"param":"a"}`), "sha-256=abc")},
+		{name: "SkipBody", req: request(nil, "md5=:AAAA:"), skipBody: true},
+		// 跳过检查请求内容时不解析摘要字段
+		{name: "SignedSkipBody", req: signed("md5=:AAAA:"), skipBody: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := gin.New()
+			e.POST("/e", New(credFn, WithSkipBody(tt.skipBody)), func(c *gin.Context) {})
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, tt.req)
+			if w.Code != http.StatusOK {
+				t.Errorf("status = %d, want %d, body: %s", w.Code, http.StatusOK, w.Body)
+			}
+		})
+	}
+}
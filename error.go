@@ -1,21 +1,38 @@
 package ginaksk
 
-import "fmt"
+import (
+	"fmt"
+	"net/http"
+)
 
 // Error aksk的错误定义
 type Error struct {
 	// 错误消息
 	Message string `json:"message"`
+	// HTTP状态码
+	status int
 }
 
 func newError(msg string) *Error {
 	return &Error{Message: msg}
 }
 
+func newStatusError(status int, msg string) *Error {
+	return &Error{Message: msg, status: status}
+}
+
 func (e *Error) Error() string {
 	return fmt.Sprintf("%s", e.Message)
 }
 
+// StatusCode 返回错误对应的HTTP状态码, 默认为401
+func (e *Error) StatusCode() int {
+	if e.status == 0 {
+		return http.StatusUnauthorized
+	}
+	return e.status
+}
+
 var (
 	// ErrTimestampExpired 时间戳过期
 	ErrTimestampExpired = newError("请求时间戳过期")
@@ -32,4 +49,8 @@ var (
 	ErrBodyInvalid = newError("请求内容无效")
 	// ErrBodyHashInvalid 请求内容哈希值无效
 	ErrBodyHashInvalid = newError("请求内容哈希值无效")
+	// ErrBodyHashEmpty 严格模式下请求缺少内容哈希值
+	ErrBodyHashEmpty = newError("请求缺少内容哈希值")
+	// ErrContentLengthMismatch 严格模式下请求内容的长度与Content-Length不一致
+	ErrContentLengthMismatch = newStatusError(http.StatusBadRequest, "请求内容长度与Content-Length不一致")
 )
@@ -0,0 +1,108 @@
+package ginaksk
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+)
+
+const (
+	// accessKeyRandomLength accessKey中随机部分的字节数
+	accessKeyRandomLength = 10
+	// accessKeyChecksumLength accessKey中校验和的字节数
+	accessKeyChecksumLength = 4
+	// defaultSecretLength secretKey默认的随机字节数
+	defaultSecretLength = 32
+)
+
+// ErrAccessKeyInvalid accessKey格式无效
+var ErrAccessKeyInvalid = newError("accesskey格式无效")
+
+// KeyGenerator 生成accessKey和secretKey
+//
+// accessKey由前缀和编码后的随机字节及其校验和组成, 可以在查询secretKey前通过Check发现输入错误;
+// secretKey为指定长度的密码学随机字节, accessKey和secretKey都使用SetEncoder设置的编码格式
+type KeyGenerator struct {
+	// Prefix accessKey的前缀, 如AKPROD
+	Prefix string
+	// SecretLength secretKey的随机字节数, 小于等于0时使用默认值32
+	SecretLength int
+}
+
+// NewKeyGenerator 返回一个使用prefix作为accessKey前缀的KeyGenerator
+func NewKeyGenerator(prefix string, secretLength int) *KeyGenerator {
+	return &KeyGenerator{Prefix: prefix, SecretLength: secretLength}
+}
+
+// Generate 生成一对accessKey和secretKey
+func (g *KeyGenerator) Generate() (accessKey, secretKey string, err error) {
+	if accessKey, err = g.AccessKey(); err != nil {
+		return "", "", err
+	}
+	if secretKey, err = g.SecretKey(); err != nil {
+		return "", "", err
+	}
+	return accessKey, secretKey, nil
+}
+
+// AccessKey 生成一个带前缀和校验和的accessKey
+func (g *KeyGenerator) AccessKey() (string, error) {
+	b, err := randomBytes(accessKeyRandomLength)
+	if err != nil {
+		return "", err
+	}
+	b = append(b, g.checksum(b)...)
+	return g.Prefix + encoder.EncodeToString(b), nil
+}
+
+// SecretKey 生成一个随机的secretKey
+func (g *KeyGenerator) SecretKey() (string, error) {
+	n := g.SecretLength
+	if n <= 0 {
+		n = defaultSecretLength
+	}
+	b, err := randomBytes(n)
+	if err != nil {
+		return "", err
+	}
+	return encoder.EncodeToString(b), nil
+}
+
+// Check 校验accessKey的前缀和校验和, 无效时返回ErrAccessKeyInvalid
+func (g *KeyGenerator) Check(accessKey string) error {
+	if !strings.HasPrefix(accessKey, g.Prefix) {
+		return ErrAccessKeyInvalid
+	}
+	b, err := encoder.DecodeString(strings.TrimPrefix(accessKey, g.Prefix))
+	if err != nil || len(b) != accessKeyRandomLength+accessKeyChecksumLength {
+		return ErrAccessKeyInvalid
+	}
+	n := len(b) - accessKeyChecksumLength
+	if !bytes.Equal(b[n:], g.checksum(b[:n])) {
+		return ErrAccessKeyInvalid
+	}
+	return nil
+}
+
+// checksum 计算前缀和随机字节的校验和
+func (g *KeyGenerator) checksum(b []byte) []byte {
+	h := crc32.NewIEEE()
+	h.Write([]byte(g.Prefix))
+	h.Write(b)
+	sum := make([]byte, accessKeyChecksumLength)
+	binary.BigEndian.PutUint32(sum, h.Sum32())
+	return sum
+}
+
+// randomBytes 读取n个密码学随机字节
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return nil, fmt.Errorf("读取随机字符串发生错误:%w", err)
+	}
+	return b, nil
+}
@@ -0,0 +1,96 @@
+package ginaksk
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestKeyGenerator(t *testing.T) {
+	t.Cleanup(cleanup)
+	g := NewKeyGenerator("AKPROD", 16)
+	ak, sk, err := g.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("ak: %s, sk: %s", ak, sk)
+	if b, _ := encoder.DecodeString(sk); len(b) != 16 {
+		t.Errorf("SecretKey() length = %d, want 16", len(b))
+	}
+	typo := []byte(ak)
+	if typo[len(typo)-1] == 'a' {
+		typo[len(typo)-1] = 'b'
+	} else {
+		typo[len(typo)-1] = 'a'
+	}
+	tests := []struct {
+		name    string
+		g       *KeyGenerator
+		ak      string
+		wantErr bool
+	}{
+		{
+			name: "Ok",
+			g:    g,
+			ak:   ak,
+		},
+		{
+			name:    "Typo",
+			g:       g,
+			ak:      string(typo),
+			wantErr: true,
+		},
+		{
+			name:    "OtherPrefix",
+			g:       NewKeyGenerator("AKTEST", 0),
+			ak:      ak,
+			wantErr: true,
+		},
+		{
+			name:    "NoChecksum",
+			g:       NewKeyGenerator("", 0),
+			ak:      "202cb962ac59075b964b07152d234b70",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.g.Check(tt.ak); (err != nil) != tt.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	t.Run("Base64", func(t *testing.T) {
+		SetEncoder(&base64Encoder{enc: base64.RawURLEncoding})
+		ak, _, err := g.Generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := g.Check(ak); err != nil {
+			t.Errorf("Check() error = %v", err)
+		}
+	})
+}
+
+func TestValidateWithAccessKeyCheck(t *testing.T) {
+	t.Cleanup(cleanup)
+	g := NewKeyGenerator("AKPROD", 0)
+	ak, sk, _ := g.Generate()
+	var lookups int
+	keyFn := func(string) string {
+		lookups++
+		return sk
+	}
+	h := Validate(keyFn, false, nil, WithAccessKeyCheck(g.Check))
+	f, _ := NewRequestFunc(ak+"x", sk)
+	req, _ := f(context.TODO(), "POST", `http://localhost:8080/e`, []byte(`{"param":"a"}`))
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = req
+	h(c)
+	if !c.IsAborted() || lookups != 0 {
+		t.Errorf("aborted = %v, lookups = %d, want aborted without lookup", c.IsAborted(), lookups)
+	}
+}
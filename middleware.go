@@ -2,9 +2,9 @@ package ginaksk
 
 import (
 	"bytes"
-	"fmt"
 	"io/ioutil"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -18,79 +18,334 @@ func handleError(c *gin.Context, err error) {
 		e = &Error{Message: err.Error()}
 	}
 	logger.Printf("验证请求错误: %s", err)
-	c.AbortWithStatusJSON(http.StatusUnauthorized, e)
+	c.AbortWithStatusJSON(e.StatusCode(), e)
 }
 
 // initialized 初始化完成
 var initialized bool
 
-// Validate 返回一个验证请求的gin中间件, keyFn指定了查询SecretKey的函数,如果等于nil,将panic; 如果skipBody为true, 跳过检查body的hash值是否一致; fn不为nil时,使用自定义的错误处理函数
-func Validate(keyFn KeyFunc, skipBody bool, fn ErrorHandler) gin.HandlerFunc {
-	logger.Printf("启用aksk认证")
+// Validate 返回一个验证请求的gin中间件, keyFn指定了查询SecretKey的函数,如果等于nil,将panic; 如果skipBody为true, 跳过检查body的hash值是否一致; fn不为nil时,使用自定义的错误处理函数; opts为可选配置;
+// 为了兼容以前的版本, 请求内容默认去除首尾空白后计算哈希值, 可以使用WithBodyCanonicalization(BodyExact)修改
+func Validate(keyFn KeyFunc, skipBody bool, fn ErrorHandler, opts ...Option) gin.HandlerFunc {
 	if keyFn == nil {
 		panic("keyFn等于nil")
 	}
-	if fn == nil {
-		fn = handleError
+	return New(keyFn.credentialFunc(), append(legacyOptions(skipBody, fn), opts...)...)
+}
+
+// New 返回一个验证请求的gin中间件, credFn指定了查询凭证的函数,如果等于nil,将panic; opts为可选配置;
+// 请求内容默认按照原始字节计算哈希值, 与NewRequestFunc的默认值一致
+//
+// 验证通过的身份保存在gin.Context和c.Request.Context()中, 使用GetPrincipal或PrincipalFromContext获取;
+// 后续的RequireScopes等中间件使用同一个错误处理函数
+func New(credFn CredentialFunc, opts ...Option) gin.HandlerFunc {
+	logger.Printf("启用aksk认证")
+	if credFn == nil {
+		panic("credFn等于nil")
 	}
 	// 使用Validate后,设置已初始化,限制调用SetHash,SetLogger,SetEncoder函数
 	initialized = true
+	v := &validator{credFn: credFn, options: newOptions(opts...)}
 	return func(c *gin.Context) {
-		if err := validRequest(c, keyFn, skipBody); err != nil {
-			fn(c, err)
-			if !c.IsAborted() {
-				c.Abort()
+		if v.exempt(c.Request.URL.Path) {
+			return
+		}
+		c.Set(errorHandlerKey, v.errorHandler)
+		defer releaseSpool(c)
+		if v.optional && anonymous(c) {
+			// 匿名请求没有凭证, 使用全局的限制
+			if err := limitBody(c, v.maxBodySize); err != nil {
+				abort(c, err)
+				return
 			}
+			c.Set(anonymousKey, true)
+		} else if err := v.validRequest(c); err != nil {
+			abort(c, err)
+			return
+		}
+		if err := v.authorize(c); err != nil {
+			abort(c, err)
+			return
 		}
+		nextStream(c)
+	}
+}
+
+// Middleware 中间件的共享配置, 可以为不同的路由组派生不同配置的中间件, 如:
+//
+//	base := ginaksk.NewMiddleware(credFn, ginaksk.WithExemptPaths("/healthz", "/metrics"))
+//	api := e.Group("/api", base.Handler())
+//	upload := e.Group("/upload", base.With(ginaksk.WithSkipBody(true)).Handler())
+type Middleware struct {
+	credFn CredentialFunc
+	opts   []Option
+}
+
+// NewMiddleware 返回一个中间件配置, 参数含义同New
+func NewMiddleware(credFn CredentialFunc, opts ...Option) *Middleware {
+	if credFn == nil {
+		panic("credFn等于nil")
+	}
+	return &Middleware{credFn: credFn, opts: opts}
+}
+
+// With 返回在当前配置上追加opts的新配置, 不修改当前配置
+func (m *Middleware) With(opts ...Option) *Middleware {
+	ss := make([]Option, 0, len(m.opts)+len(opts))
+	ss = append(ss, m.opts...)
+	return &Middleware{credFn: m.credFn, opts: append(ss, opts...)}
+}
+
+// Handler 返回验证请求的gin中间件
+func (m *Middleware) Handler() gin.HandlerFunc {
+	return New(m.credFn, m.opts...)
+}
+
+// VerifyRequest 在gin之外校验HTTP请求r, 参数含义同Validate, 验证通过返回nil;
+// 分块签名、尾部哈希值和WithStreamingBody的请求内容在返回前读取到EOF并验证, 验证通过后r.Body为读取的内容;
+// 使用WithMultipartSpool时, 调用者需要关闭r.Body删除临时文件
+func VerifyRequest(r *http.Request, keyFn KeyFunc, skipBody bool, opts ...Option) error {
+	if keyFn == nil {
+		panic("keyFn等于nil")
+	}
+	initialized = true
+	v := &validator{credFn: keyFn.credentialFunc(), options: newOptions(append(legacyOptions(skipBody, nil), opts...)...)}
+	c := &gin.Context{Request: r}
+	if err := v.validRequest(c); err != nil {
+		return err
+	}
+	if err := readStream(c); err != nil {
+		return err
 	}
+	// setPrincipal替换了c.Request
+	r.Body = c.Request.Body
+	return nil
+}
+
+// legacyOptions 返回Validate和VerifyRequest的参数对应的配置, 请求内容默认去除首尾空白后计算哈希值
+func legacyOptions(skipBody bool, fn ErrorHandler) []Option {
+	return []Option{WithSkipBody(skipBody), WithErrorHandler(fn), WithBodyCanonicalization(BodyTrimmed)}
+}
+
+// validator 请求验证器
+type validator struct {
+	credFn CredentialFunc
+	options
 }
 
 func validRequest(c *gin.Context, keyFn KeyFunc, skipBody bool) error {
+	v := &validator{credFn: keyFn.credentialFunc(), options: newOptions(legacyOptions(skipBody, nil)...)}
+	return v.validRequest(c)
+}
+
+func (v *validator) validRequest(c *gin.Context) error {
 	ak := c.GetHeader(headerAccessKey)
 	if ak == "" {
 		return ErrAccessKeyEmpty
 	}
-	sk := keyFn(ak)
-	if sk == "" {
-		return ErrSecretKeyEmpty
+	token := c.GetHeader(headerSessionToken)
+	cred, parent, err := v.credential(ak, token)
+	if err != nil {
+		return err
+	}
+	p := newPrincipal(cred)
+	maxBodySize := v.maxBodySize
+	if cred.MaxBodySize > 0 {
+		maxBodySize = cred.MaxBodySize
+	}
+	if err := limitBody(c, maxBodySize); err != nil {
+		return err
 	}
+	sk := cred.SecretKey
 	ts := c.GetHeader(headerTimestamp)
 	// 兼容以前的错误拼写
 	if ts == "" {
 		ts = c.GetHeader(`x-auth-timestramp`)
 	}
-	if err := parseTimestamp(ts); err != nil {
+	t, err := parseTimestamp(ts, v.maxAge, v.maxAhead)
+	if err != nil {
 		return err
 	}
+	p.Skew = time.Since(t)
 	signature := c.GetHeader(headerSignature)
 	if signature == "" {
 		return ErrSignatueEmpty
 	}
 	bodyhash := c.GetHeader(headerBodyHash)
-	randomstr := c.GetHeader(headerRandomStr)
-	if err := validSignature(sk, signature, ak, ts, randomstr, bodyhash); err != nil {
+	h := signedHeaders{
+		accessKey: ak,
+		timestamp: ts,
+		randomStr: c.GetHeader(headerRandomStr),
+		bodyHash:  bodyhash,
+	}
+	var digests []string
+	if bodyhash == ContentDigestPayload {
+		// 客户端声明使用RFC 9530的摘要字段, 摘要字段参与签名
+		if digests = digestFields(c.Request); len(digests) == 0 {
+			return ErrBodyHashEmpty
+		}
+		h.optional = append(h.optional, digests...)
+	}
+	if v.strict && !v.skipBody && bodyhash == "" {
+		return ErrBodyHashEmpty
+	}
+	if s := c.GetHeader(headerCredentialScope); s != "" {
+		scope, err := v.credentialScope(s, t)
+		if err != nil {
+			return err
+		}
+		if v.revocations != nil && v.revocations.RevokedAt(ak, scope.Date) {
+			return ErrAccessKeyRevoked
+		}
+		sk = DeriveSigningKey(sk, scope)
+		h.optional = append(h.optional, s)
+		p.Scheme, p.CredentialScope = SchemeDerivedKey, scope
+	}
+	if token != "" {
+		h.optional = append(h.optional, token)
+		p.Scheme, p.ParentAccessKey = SchemeSession, parent
+	}
+	if err := validSignature(sk, signature, h.elems()...); err != nil {
+		if v.debug {
+			v.explainSignature(c, sk, signature, h)
+		}
 		return err
 	}
-	if skipBody {
-		return nil
+	if err := v.allowSource(c.Request, cred); err != nil {
+		return err
 	}
+	switch {
+	case bodyhash == StreamingPayload:
+		if !v.streamingPayload {
+			return ErrStreamingPayloadDenied
+		}
+		decodeChunks(c, sk, signature)
+	case v.skipBody:
+		p.UnsignedPayload = bodyhash == UnsignedPayload
+	case bodyhash == UnsignedPayload:
+		if !v.unsignedPayload(c.Request.URL.Path, cred) {
+			return ErrUnsignedPayloadDenied
+		}
+		p.UnsignedPayload = true
+	case bodyhash == TrailerPayload:
+		if !v.trailer {
+			return ErrTrailerPayloadDenied
+		}
+		trailerBody(c, sk, signature)
+	case bodyhash == ContentDigestPayload:
+		d, err := newDigester(digests)
+		if err != nil {
+			return err
+		}
+		if err := v.verifyDigest(c, d); err != nil {
+			return err
+		}
+	case v.streaming:
+		streamBody(c, bodyhash, v.strict)
+	case v.spoolThreshold > 0 && isMultipart(c.GetHeader("Content-Type")):
+		if err := v.spoolBody(c, bodyhash, nil); err != nil {
+			return err
+		}
+	default:
+		b, err := v.readBody(c)
+		if err != nil {
+			return err
+		}
+		if v.strict {
+			if err := validBytesStrict(b, bodyhash); err != nil {
+				return err
+			}
+		} else if err := validBytes(b, bodyhash); err != nil {
+			return ErrBodyInvalid
+		}
+	}
+	setPrincipal(c, p)
+	return nil
+}
+
+// credential 查询accessKey对应的凭证, 携带会话令牌时使用签发者的凭证和令牌中的临时secretKey,
+// 权限范围为令牌中签发者当前仍然拥有的权限范围, 并返回签发者的accessKey
+func (v *validator) credential(ak, token string) (*Credential, string, error) {
+	if token != "" {
+		if v.sessions == nil {
+			return nil, "", ErrSessionTokenInvalid
+		}
+		claims, sk, err := v.sessions.parse(token)
+		if err != nil {
+			return nil, "", err
+		}
+		if claims.AccessKey != ak {
+			return nil, "", ErrSessionTokenInvalid
+		}
+		if v.revocations != nil && (v.revocations.Revoked(ak) ||
+			v.revocations.RevokedAt(claims.ParentAccessKey, time.Unix(claims.IssuedAt, 0))) {
+			return nil, "", ErrAccessKeyRevoked
+		}
+		// 临时凭证继承签发者的来源IP、访问策略和限制, 只替换accessKey, secretKey和权限范围
+		parent, err := v.credFn(claims.ParentAccessKey)
+		if err != nil {
+			return nil, "", err
+		}
+		if parent == nil {
+			return nil, "", ErrSessionTokenInvalid
+		}
+		if parent.SecretKey == "" {
+			return nil, "", ErrSecretKeyEmpty
+		}
+		// 只保留签发者当前仍然拥有的权限范围
+		scopes := make([]string, 0, len(claims.Scopes))
+		for _, s := range claims.Scopes {
+			if hasScope(parent.Scopes, s) {
+				scopes = append(scopes, s)
+			}
+		}
+		cred := *parent
+		cred.AccessKey, cred.SecretKey, cred.Scopes = ak, sk, scopes
+		return &cred, claims.ParentAccessKey, nil
+	}
+	if v.checkAccessKey != nil {
+		if err := v.checkAccessKey(ak); err != nil {
+			return nil, "", err
+		}
+	}
+	if v.revocations != nil && v.revocations.Revoked(ak) {
+		return nil, "", ErrAccessKeyRevoked
+	}
+	cred, err := v.credFn(ak)
+	if err != nil {
+		return nil, "", err
+	}
+	if cred == nil || cred.SecretKey == "" {
+		return nil, "", ErrSecretKeyEmpty
+	}
+	return cred, "", nil
+}
+
+// readBody 读取body, 返回规范化后的内容, 严格模式下检查请求内容的长度
+func (v *validator) readBody(c *gin.Context) ([]byte, error) {
 	b, err := readBody(c)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if err := validBytes(b, bodyhash); err != nil {
-		return ErrBodyInvalid
+	if v.strict && c.Request.ContentLength >= 0 && int64(len(b)) != c.Request.ContentLength {
+		return nil, ErrContentLengthMismatch
 	}
-	return nil
+	if b, err = v.canonical.canonicalize(b, c.GetHeader("Content-Type")); err != nil {
+		return nil, ErrBodyInvalid
+	}
+	return b, nil
 }
 
 // readBody 读取body
 func readBody(c *gin.Context) ([]byte, error) {
+	if c.Request.Body == nil {
+		return nil, nil
+	}
 	b, err := ioutil.ReadAll(c.Request.Body)
 	if err != nil {
-		return nil, fmt.Errorf("读取Body发生错误: %s", err)
+		return nil, readError(err)
 	}
 	c.Request.Body = ioutil.NopCloser(bytes.NewReader(b))
 
-	return bytes.TrimSpace(b), nil
+	return b, nil
 }
@@ -7,8 +7,11 @@ import (
 	"encoding/base64"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -334,3 +337,90 @@ func Test_validRequestWithMD5AndBase64(t *testing.T) {
 		})
 	}
 }
+
+func TestMiddlewareWith(t *testing.T) {
+	t.Cleanup(cleanup)
+	gin.SetMode(gin.TestMode)
+	credFn := func(ak string) (*Credential, error) {
+		return &Credential{AccessKey: ak, SecretKey: "sk", Scopes: []string{"orders:read"}}, nil
+	}
+	base := NewMiddleware(credFn, WithExemptPaths("/healthz", "/webhooks/**"))
+	e := gin.New()
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	root := e.Group("", base.Handler())
+	root.GET("/healthz", ok)
+	root.POST("/webhooks/:name", ok)
+	root.POST("/api/orders", ok)
+	upload := e.Group("/upload", base.With(WithSkipBody(true)).Handler())
+	upload.POST("/files", ok)
+	admin := e.Group("/admin", base.With(WithRequiredScopes("admin")).Handler())
+	admin.GET("/users", ok)
+
+	signed := func(method, url string, tampered bool) *http.Request {
+		f, _ := NewRequestFunc("ak", "sk")
+		req, _ := f(context.TODO(), method, "http://localhost"+url, []byte(`{"param":"a"}`))
+		if tampered {
+			req.Body = ioutil.NopCloser(strings.NewReader(`{"param":"b"}`))
+		}
+		return req
+	}
+	tests := []struct {
+		name string
+		req  *http.Request
+		want int
+	}{
+		{name: "Healthz", req: httptest.NewRequest("GET", "/healthz", nil), want: http.StatusOK},
+		{name: "Webhook", req: httptest.NewRequest("POST", "/webhooks/github", nil), want: http.StatusOK},
+		{name: "Unsigned", req: httptest.NewRequest("POST", "/api/orders", nil), want: http.StatusUnauthorized},
+		{name: "Signed", req: signed("POST", "/api/orders", false), want: http.StatusOK},
+		{name: "BodyVerified", req: signed("POST", "/api/orders", true), want: http.StatusUnauthorized},
+		{name: "UploadSkipBody", req: signed("POST", "/upload/files", true), want: http.StatusOK},
+		{name: "AdminScope", req: signed("GET", "/admin/users", false), want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, tt.req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d, body: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestTimestampWindow(t *testing.T) {
+	t.Cleanup(cleanup)
+	keyFn := func(string) string {
+		return "sk"
+	}
+	old := func(d time.Duration) *http.Request {
+		ts := strconv.FormatInt(time.Now().Add(-d).Unix(), 10)
+		h := signedHeaders{accessKey: "ak", timestamp: ts, randomStr: "r"}
+		req := httptest.NewRequest("GET", "/e", nil)
+		req.Header.Set(headerAccessKey, "ak")
+		req.Header.Set(headerTimestamp, ts)
+		req.Header.Set(headerRandomStr, "r")
+		req.Header.Set(headerSignature, encoder.EncodeToString(hmacSum([]byte("sk"), h.elems()...)))
+		return req
+	}
+	tests := []struct {
+		name    string
+		age     time.Duration
+		opts    []Option
+		wantErr error
+	}{
+		{name: "Default", age: 4 * time.Minute},
+		{name: "DefaultExpired", age: 6 * time.Minute, wantErr: ErrTimestampExpired},
+		{name: "DefaultAhead", age: -2 * time.Minute, wantErr: ErrTimestampInvalid},
+		{name: "Shorter", age: 2 * time.Minute, opts: []Option{WithTimestampWindow(time.Minute, -1)}, wantErr: ErrTimestampExpired},
+		{name: "Longer", age: 10 * time.Minute, opts: []Option{WithTimestampWindow(15*time.Minute, -1)}},
+		{name: "LongerAhead", age: -2 * time.Minute, opts: []Option{WithTimestampWindow(-1, 3*time.Minute)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := VerifyRequest(old(tt.age), keyFn, true, tt.opts...); err != tt.wantErr {
+				t.Errorf("VerifyRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package ginaksk
+
+import (
+	"net"
+	"time"
+)
+
+// Option Validate中间件的可选配置
+type Option func(o *options)
+
+// options 中间件的配置项
+type options struct {
+	// skipBody 跳过检查body的hash值
+	skipBody bool
+	// errorHandler 错误处理函数
+	errorHandler ErrorHandler
+	// checkAccessKey 查询secretKey前校验accessKey
+	checkAccessKey func(accessKey string) error
+	// debug 签名验证失败时输出诊断信息
+	debug bool
+	// debugKeys 允许在响应头部返回诊断信息的accessKey
+	debugKeys map[string]bool
+	// scopeService 接受派生签名密钥的服务名称, 为空时不接受派生的签名密钥
+	scopeService string
+	// scopePurposes 接受的派生签名密钥用途, 为空时不限制
+	scopePurposes map[string]bool
+	// sessions 校验临时会话凭证, 为nil时不接受临时会话凭证
+	sessions *SessionIssuer
+	// revocations 吊销列表
+	revocations *RevocationList
+	// scopeRules 路由需要的权限范围
+	scopeRules []ScopeRule
+	// trustedProxies 可信代理, 只有来自可信代理的请求才使用X-Forwarded-For和X-Real-IP
+	trustedProxies []*net.IPNet
+	// exemptPaths 不需要验证的路径
+	exemptPaths []string
+	// maxAge 时间戳在过去多长时间之内有效
+	maxAge time.Duration
+	// maxAhead 时间戳在未来多长时间之内有效
+	maxAhead time.Duration
+	// requiredScopes 所有请求需要的权限范围
+	requiredScopes []string
+	// optional 允许没有携带凭证的匿名请求
+	optional bool
+	// streaming 流式验证请求内容
+	streaming bool
+	// maxBodySize 请求内容的大小限制
+	maxBodySize int64
+	// canonical 请求内容的规范化方式
+	canonical BodyCanonicalization
+	// strict 严格检查请求内容的哈希值
+	strict bool
+	// unsignedPaths 允许使用未签名的请求内容的路径
+	unsignedPaths []string
+	// trailer 允许在HTTP尾部发送请求内容的哈希值
+	trailer bool
+	// streamingPayload 允许分块签名的请求内容
+	streamingPayload bool
+	// spoolThreshold multipart/form-data请求内容的内存暂存上限, 等于0时不暂存
+	spoolThreshold int64
+}
+
+func newOptions(opts ...Option) options {
+	o := options{
+		errorHandler: handleError,
+		maxAge:       maxDuration,
+		maxAhead:     -minDuration,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	return o
+}
+
+// WithSkipBody 为true时跳过检查body的hash值是否一致
+func WithSkipBody(skipBody bool) Option {
+	return func(o *options) {
+		o.skipBody = skipBody
+	}
+}
+
+// WithErrorHandler 使用自定义的错误处理函数, fn为nil时使用默认的错误处理函数
+func WithErrorHandler(fn ErrorHandler) Option {
+	return func(o *options) {
+		if fn == nil {
+			fn = handleError
+		}
+		o.errorHandler = fn
+	}
+}
+
+// WithAccessKeyCheck 在调用KeyFunc前使用fn校验accessKey, 如KeyGenerator.Check, 校验失败的请求不会查询secretKey
+func WithAccessKeyCheck(fn func(accessKey string) error) Option {
+	return func(o *options) {
+		o.checkAccessKey = fn
+	}
+}
+
+// WithDebug 开启调试模式, 签名验证失败时在日志中输出服务端的待签名字符串、算法和可能不一致的输入;
+// accessKeys中的accessKey还会在响应头部x-auth-debug中收到这些信息, 不要在生产环境中开启;
+// 签名验证已经失败, accessKeys只与请求头部中的accessKey比较, 不能验证收到诊断信息的是谁;
+// 请求内容超过64KB时不诊断请求内容
+func WithDebug(accessKeys ...string) Option {
+	return func(o *options) {
+		o.debug = true
+		o.debugKeys = make(map[string]bool, len(accessKeys))
+		for _, ak := range accessKeys {
+			o.debugKeys[ak] = true
+		}
+	}
+}
+
+// WithDerivedKeys 接受由DeriveSigningKey派生的签名密钥, service为当前服务的名称, purposes为空时不限制用途;
+// 请求头部x-auth-credential-scope声明的日期必须与请求时间戳的UTC日期一致
+func WithDerivedKeys(service string, purposes ...string) Option {
+	return func(o *options) {
+		o.scopeService = service
+		o.scopePurposes = make(map[string]bool, len(purposes))
+		for _, p := range purposes {
+			o.scopePurposes[p] = true
+		}
+	}
+}
+
+// WithSessionIssuer 接受s签发的临时会话凭证, 携带x-auth-session-token头部的请求使用令牌中的临时secretKey验证签名;
+// 中间件使用KeyFunc查询签发者的凭证, 签发者不存在时返回ErrSessionTokenInvalid, 临时凭证继承签发者的AllowedCIDRs, Policy和各项限制
+func WithSessionIssuer(s *SessionIssuer) Option {
+	return func(o *options) {
+		o.sessions = s
+	}
+}
+
+// WithRevocationList 在查询secretKey前检查吊销列表l, 已吊销的accessKey返回ErrAccessKeyRevoked
+func WithRevocationList(l *RevocationList) Option {
+	return func(o *options) {
+		o.revocations = l
+	}
+}
+
+// WithTrustedProxies 设置可信代理的IP或CIDR, 无效时panic; 只有直接连接的地址是可信代理时,
+// 才从右向左查找X-Forwarded-For中第一个不是可信代理的地址, 或者使用X-Real-IP作为客户端IP
+func WithTrustedProxies(cidrs ...string) Option {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, s := range cidrs {
+		n, err := parseCIDR(s)
+		if err != nil {
+			panic("可信代理无效: " + err.Error())
+		}
+		nets = append(nets, n)
+	}
+	return func(o *options) {
+		o.trustedProxies = append(o.trustedProxies, nets...)
+	}
+}
+
+// WithExemptPaths 不验证匹配patterns的请求路径, 如/healthz, /metrics, /webhooks/**, 模式的格式同ScopeRule.Path
+func WithExemptPaths(patterns ...string) Option {
+	return func(o *options) {
+		o.exemptPaths = append(o.exemptPaths, patterns...)
+	}
+}
+
+// exempt 返回路径p是否不需要验证
+func (o *options) exempt(p string) bool {
+	for _, pattern := range o.exemptPaths {
+		if matchPath(pattern, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithTimestampWindow 设置时间戳的有效范围, 默认为过去5分钟到未来1分钟, 小于0的参数被忽略
+func WithTimestampWindow(maxAge, maxAhead time.Duration) Option {
+	return func(o *options) {
+		if maxAge >= 0 {
+			o.maxAge = maxAge
+		}
+		if maxAhead >= 0 {
+			o.maxAhead = maxAhead
+		}
+	}
+}
+
+// WithRequiredScopes 验证通过后要求凭证有scopes中的全部权限范围, 否则返回ErrScopeDenied
+func WithRequiredScopes(scopes ...string) Option {
+	return func(o *options) {
+		o.requiredScopes = append(o.requiredScopes, scopes...)
+	}
+}
+
+// WithOptionalAuth 允许没有携带凭证的请求匿名通过, 携带了凭证的请求仍然完整验证, 验证失败时拒绝;
+// 处理函数使用IsAnonymous或GetPrincipal区分匿名请求, 匿名请求访问需要权限范围的路由时返回ErrAccessKeyEmpty
+func WithOptionalAuth() Option {
+	return func(o *options) {
+		o.optional = true
+	}
+}
+
+// WithStreamingBody 不再缓存请求内容, 而是在处理函数读取时计算哈希值, 读取到EOF时与x-auth-body-hash比较;
+// 不一致时Read返回ErrBodyInvalid, 如果处理函数还没有写入响应, 中间件使用错误处理函数终止请求;
+// 处理函数返回后中间件读取剩余的请求内容并验证, 没有读取到EOF的请求内容不一致时同样终止请求或记录日志;
+// 处理函数必须读取到EOF并检查读取错误后才能信任请求内容, 流式验证按照原始字节计算哈希值, 不去除首尾空白
+func WithStreamingBody() Option {
+	return func(o *options) {
+		o.streaming = true
+	}
+}
+
+// WithMaxBodySize 限制请求内容不超过n字节, 小于等于0时不限制, 凭证的MaxBodySize大于0时使用凭证的限制;
+// Content-Length超过限制时不读取请求内容, 直接返回413和ErrBodyTooLarge, 读取时超过限制返回ErrBodyTooLarge;
+// WithOptionalAuth的匿名请求同样受到限制, 处理函数读取时返回ErrBodyTooLarge
+func WithMaxBodySize(n int64) Option {
+	return func(o *options) {
+		o.maxBodySize = n
+	}
+}
+
+// WithBodyCanonicalization 设置计算请求内容哈希值前的规范化方式, 必须与客户端的WithRequestBodyCanonicalization一致;
+// New默认为BodyExact, Validate和VerifyRequest默认为BodyTrimmed, 流式验证总是使用原始字节
+func WithBodyCanonicalization(m BodyCanonicalization) Option {
+	return func(o *options) {
+		o.canonical = m
+	}
+}
+
+// WithStrictBodyHash 开启严格模式, 检查请求内容时总是要求x-auth-body-hash, 空的请求内容使用空字符串的哈希值,
+// 客户端需要使用WithRequestStrictBodyHash; 缺少时返回ErrBodyHashEmpty, 无法解码时返回ErrBodyHashInvalid,
+// 读取的请求内容长度与Content-Length不一致时返回ErrContentLengthMismatch; WithSkipBody(true)时不检查
+func WithStrictBodyHash() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// WithMultipartSpool 边读取边计算multipart/form-data请求内容的哈希值, 不超过threshold字节时暂存在内存中,
+// 否则写入临时文件, 验证通过后处理函数读取暂存的请求内容, 请求结束后删除临时文件; threshold小于等于0时为32MB;
+// 暂存的请求内容按照原始字节计算哈希值, 不使用WithBodyCanonicalization; 同时开启WithStreamingBody时使用流式验证
+func WithMultipartSpool(threshold int64) Option {
+	return func(o *options) {
+		if threshold <= 0 {
+			threshold = defaultSpoolThreshold
+		}
+		o.spoolThreshold = threshold
+	}
+}
+
+// WithUnsignedPayloadPaths 允许匹配patterns的请求路径使用未签名的请求内容, 模式的格式同ScopeRule.Path;
+// 客户端使用WithRequestUnsignedPayload发送x-auth-body-hash: UNSIGNED-PAYLOAD, 该头部参与签名,
+// 路径不匹配并且凭证的AllowUnsignedPayload为false时返回ErrUnsignedPayloadDenied
+func WithUnsignedPayloadPaths(patterns ...string) Option {
+	return func(o *options) {
+		o.unsignedPaths = append(o.unsignedPaths, patterns...)
+	}
+}
+
+// WithTrailerPayload 允许客户端使用NewTrailerRequestFunc发送x-auth-body-hash: STREAMING-TRAILER,
+// 在HTTP尾部发送请求内容的哈希值, 请求内容由处理函数读取时验证, 处理函数返回后中间件读取剩余的请求内容;
+// 处理函数必须读取到EOF并检查读取错误后才能信任请求内容, 没有使用该选项时返回ErrTrailerPayloadDenied
+func WithTrailerPayload() Option {
+	return func(o *options) {
+		o.trailer = true
+	}
+}
+
+// WithStreamingPayload 允许客户端使用NewStreamingRequestFunc发送x-auth-body-hash: STREAMING-PAYLOAD,
+// 请求内容分块签名, 由处理函数读取时逐个分块验证, 处理函数返回后中间件读取剩余的请求内容;
+// 处理函数必须读取到EOF并检查读取错误后才能信任请求内容, 没有使用该选项时返回ErrStreamingPayloadDenied
+func WithStreamingPayload() Option {
+	return func(o *options) {
+		o.streamingPayload = true
+	}
+}
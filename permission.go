@@ -0,0 +1,142 @@
+package ginaksk
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrScopeDenied 凭证没有路由需要的权限范围
+var ErrScopeDenied = newStatusError(http.StatusForbidden, "accesskey没有访问权限")
+
+// ScopeRule 路由需要的权限范围
+type ScopeRule struct {
+	// Method 请求方法, 为空或*时匹配所有方法
+	Method string
+	// Path 路径模式, *和:name匹配任意一段路径, 末尾的**匹配剩余的所有路径, 如/orders/:id, /orders/**
+	Path string
+	// Scopes 需要的权限范围, 必须全部满足
+	Scopes []string
+}
+
+// match 返回规则是否匹配请求
+func (r *ScopeRule) match(method, p string) bool {
+	if r.Method != "" && r.Method != "*" && !strings.EqualFold(r.Method, method) {
+		return false
+	}
+	return matchPath(r.Path, p)
+}
+
+// WithScopeRules 验证通过后按照顺序查找第一条匹配请求的规则, 凭证没有规则要求的权限范围时返回ErrScopeDenied
+func WithScopeRules(rules ...ScopeRule) Option {
+	return func(o *options) {
+		o.scopeRules = append(o.scopeRules, rules...)
+	}
+}
+
+// RequireScopes 返回一个检查权限范围的gin中间件, 必须在Validate或New返回的中间件之后使用;
+// 凭证没有scopes中的全部权限范围时返回ErrScopeDenied
+func RequireScopes(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cred := credential(c)
+		if cred == nil {
+			abort(c, ErrAccessKeyEmpty)
+			return
+		}
+		if !hasScopes(cred.Scopes, scopes) {
+			abort(c, ErrScopeDenied)
+		}
+	}
+}
+
+// authorize 检查验证通过的凭证是否满足路由的权限要求和访问策略, 匿名请求访问需要权限范围的路由时返回ErrAccessKeyEmpty
+func (v *validator) authorize(c *gin.Context) error {
+	cred := credential(c)
+	required := v.requiredScopes
+	for i := range v.scopeRules {
+		r := &v.scopeRules[i]
+		if r.match(c.Request.Method, c.Request.URL.Path) {
+			required = append(required[:len(required):len(required)], r.Scopes...)
+			break
+		}
+	}
+	if cred == nil {
+		if len(required) > 0 {
+			return ErrAccessKeyEmpty
+		}
+		return nil
+	}
+	if !hasScopes(cred.Scopes, required) {
+		return ErrScopeDenied
+	}
+	if cred.Policy != nil {
+		if d := cred.Policy.Evaluate(newPolicyRequest(c, v.clientIP(c.Request))); !d.Allowed {
+			logger.Printf("访问策略拒绝请求 accesskey: %s, sid: %s, %s", cred.AccessKey, d.Sid, d.Reason)
+			return ErrPolicyDenied
+		}
+	}
+	return nil
+}
+
+// hasScopes 返回granted是否包含required中的全部权限范围
+func hasScopes(granted, required []string) bool {
+	for _, s := range required {
+		if !hasScope(granted, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasScope 返回granted是否包含权限范围s, 支持*和orders:*形式的通配
+func hasScope(granted []string, s string) bool {
+	for _, g := range granted {
+		if g == s || g == "*" {
+			return true
+		}
+		if strings.HasSuffix(g, ":*") && strings.HasPrefix(s, g[:len(g)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPath 返回路径p是否匹配pattern, pattern按照/分段, 每段使用path.Match匹配,
+// :name匹配任意一段, 只有最后一段的**或*name匹配剩余的所有段, *.css等其他段只匹配一段
+func matchPath(pattern, p string) bool {
+	ps := strings.Split(strings.Trim(pattern, "/"), "/")
+	ss := strings.Split(strings.Trim(p, "/"), "/")
+	for i, seg := range ps {
+		if i == len(ps)-1 && catchAll(seg) {
+			return true
+		}
+		if i >= len(ss) {
+			return false
+		}
+		if strings.HasPrefix(seg, ":") {
+			continue
+		}
+		if ok, _ := path.Match(seg, ss[i]); !ok {
+			return false
+		}
+	}
+	return len(ps) == len(ss)
+}
+
+// catchAll 返回路径段是否是**或gin的*name
+func catchAll(seg string) bool {
+	if seg == "**" {
+		return true
+	}
+	if len(seg) < 2 || seg[0] != '*' {
+		return false
+	}
+	for _, r := range seg[1:] {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+package ginaksk
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestScopes(t *testing.T) {
+	t.Cleanup(cleanup)
+	gin.SetMode(gin.TestMode)
+	creds := map[string]*Credential{
+		"reader": {AccessKey: "reader", SecretKey: "sk", Scopes: []string{"orders:read"}},
+		"writer": {AccessKey: "writer", SecretKey: "sk", Scopes: []string{"orders:*"}},
+		"admin":  {AccessKey: "admin", SecretKey: "sk", Scopes: []string{"*"}},
+	}
+	credFn := func(ak string) (*Credential, error) {
+		return creds[ak], nil
+	}
+	e := gin.New()
+	e.Use(New(credFn, WithScopeRules(
+		ScopeRule{Method: "GET", Path: "/orders/**", Scopes: []string{"orders:read"}},
+		ScopeRule{Method: "POST", Path: "/orders/:id", Scopes: []string{"orders:write"}},
+	)))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	e.GET("/orders/:id", ok)
+	e.POST("/orders/:id", ok)
+	e.DELETE("/orders/:id", RequireScopes("orders:delete", "audit:write"), ok)
+	tests := []struct {
+		name   string
+		ak     string
+		method string
+		want   int
+	}{
+		{name: "ReaderGet", ak: "reader", method: "GET", want: http.StatusOK},
+		{name: "ReaderPost", ak: "reader", method: "POST", want: http.StatusForbidden},
+		{name: "WriterPost", ak: "writer", method: "POST", want: http.StatusOK},
+		{name: "WriterDelete", ak: "writer", method: "DELETE", want: http.StatusForbidden},
+		{name: "AdminDelete", ak: "admin", method: "DELETE", want: http.StatusOK},
+		{name: "Unknown", ak: "unknown", method: "GET", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, _ := NewRequestFunc(tt.ak, "sk")
+			req, _ := f(context.TODO(), tt.method, `http://localhost:8080/orders/1`, nil)
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("%s %s status = %d, want %d, body: %s", tt.ak, tt.method, w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func Test_matchPath(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{pattern: "/orders/:id", path: "/orders/1", want: true},
+		{pattern: "/orders/:id", path: "/orders/1/items", want: false},
+		{pattern: "/orders/*", path: "/orders/1", want: true},
+		{pattern: "/orders/**", path: "/orders", want: true},
+		{pattern: "/orders/**", path: "/orders/1/items", want: true},
+		{pattern: "/files/*filepath", path: "/files/a/b", want: true},
+		{pattern: "/v*/orders", path: "/v2/orders", want: true},
+		{pattern: "/static/*.css", path: "/static/site.css", want: true},
+		{pattern: "/static/*.css", path: "/static/admin/secret", want: false},
+		{pattern: "/files/*.json", path: "/files/x/y/z", want: false},
+		{pattern: "/files/*.json/meta", path: "/files/a.json/meta", want: true},
+		{pattern: "/files/*filepath/meta", path: "/files/a/b/meta", want: false},
+		{pattern: "/files/**/meta", path: "/files/a/meta", want: true},
+		{pattern: "/files/**/meta", path: "/files/a/b/meta", want: false},
+		{pattern: "/orders", path: "/users", want: false},
+		{pattern: "/", path: "/", want: true},
+	}
+	for _, tt := range tests {
+		if got := matchPath(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("matchPath(%s, %s) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
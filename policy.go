@@ -0,0 +1,269 @@
+package ginaksk
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrPolicyDenied 策略拒绝访问
+var ErrPolicyDenied = newStatusError(http.StatusForbidden, "访问策略拒绝请求")
+
+const (
+	// EffectAllow 允许
+	EffectAllow = "Allow"
+	// EffectDeny 拒绝
+	EffectDeny = "Deny"
+)
+
+// Policy IAM风格的访问策略, 显式拒绝优先, 没有匹配的允许语句时拒绝
+type Policy struct {
+	// Version 策略版本
+	Version string `json:"Version,omitempty"`
+	// Statement 策略语句
+	Statement []Statement `json:"Statement"`
+}
+
+// Statement 策略语句
+type Statement struct {
+	// Sid 语句标识
+	Sid string `json:"Sid,omitempty"`
+	// Effect Allow或Deny
+	Effect string `json:"Effect"`
+	// Action 请求方法和路由, 如"GET /orders/:id", "POST /orders/**", "* /orders/*", "*"
+	Action stringList `json:"Action"`
+	// Resource 请求路径的模式, 如/tenants/acme/orders/*, 为空时匹配所有路径
+	Resource stringList `json:"Resource,omitempty"`
+	// Condition 条件, 全部满足时语句才生效
+	Condition *Condition `json:"Condition,omitempty"`
+}
+
+// Condition 策略语句的条件
+type Condition struct {
+	// SourceIP 允许的来源IP或CIDR
+	SourceIP stringList `json:"SourceIp,omitempty"`
+	// After 请求时间晚于After
+	After *time.Time `json:"After,omitempty"`
+	// Before 请求时间早于Before
+	Before *time.Time `json:"Before,omitempty"`
+	// Params 路径参数必须匹配的模式, 如{"tenant": ["acme", "acme-*"]}
+	Params map[string]stringList `json:"Params,omitempty"`
+}
+
+// stringList JSON中可以是字符串或字符串数组
+type stringList []string
+
+// UnmarshalJSON 解析字符串或字符串数组
+func (l *stringList) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*l = stringList{s}
+		return nil
+	}
+	var ss []string
+	if err := json.Unmarshal(b, &ss); err != nil {
+		return err
+	}
+	*l = ss
+	return nil
+}
+
+// PolicyRequest 策略评估的请求
+type PolicyRequest struct {
+	// Method 请求方法
+	Method string
+	// Route 匹配的gin路由, 如/orders/:id, 为空时使用Path
+	Route string
+	// Path 请求路径
+	Path string
+	// Params 路径参数
+	Params map[string]string
+	// SourceIP 客户端IP
+	SourceIP net.IP
+	// Time 请求时间
+	Time time.Time
+}
+
+// Decision 策略评估的结果
+type Decision struct {
+	// Allowed 是否允许
+	Allowed bool
+	// Sid 决定结果的语句标识
+	Sid string
+	// Reason 结果说明
+	Reason string
+}
+
+// ParsePolicy 解析JSON格式的策略, 不支持的字段(如IAM的IpAddress条件)返回错误, 避免语句的限制被忽略
+func ParsePolicy(b []byte) (*Policy, error) {
+	p := new(Policy)
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.DisallowUnknownFields()
+	if err := d.Decode(p); err != nil {
+		return nil, fmt.Errorf("解析策略发生错误: %w", err)
+	}
+	if d.More() {
+		return nil, errors.New("解析策略发生错误: 包含多个JSON值")
+	}
+	for i, s := range p.Statement {
+		if s.Effect != EffectAllow && s.Effect != EffectDeny {
+			return nil, fmt.Errorf("策略语句%d的Effect无效: %s", i, s.Effect)
+		}
+		if len(s.Action) == 0 {
+			return nil, fmt.Errorf("策略语句%d缺少Action", i)
+		}
+		if s.Condition != nil {
+			for _, ip := range s.Condition.SourceIP {
+				if _, err := parseCIDR(ip); err != nil {
+					return nil, fmt.Errorf("策略语句%d的SourceIp无效: %w", i, err)
+				}
+			}
+		}
+	}
+	return p, nil
+}
+
+// Evaluate 评估策略是否允许请求r, 可以用于测试策略
+func (p *Policy) Evaluate(r *PolicyRequest) Decision {
+	d := Decision{Reason: "没有匹配的允许语句"}
+	for i := range p.Statement {
+		s := &p.Statement[i]
+		if !s.match(r) {
+			continue
+		}
+		if s.Effect == EffectDeny {
+			return Decision{Sid: s.Sid, Reason: "匹配拒绝语句"}
+		}
+		if !d.Allowed {
+			d = Decision{Allowed: true, Sid: s.Sid, Reason: "匹配允许语句"}
+		}
+	}
+	return d
+}
+
+// match 返回语句是否匹配请求
+func (s *Statement) match(r *PolicyRequest) bool {
+	route := r.Route
+	if route == "" {
+		route = r.Path
+	}
+	ok := false
+	for _, a := range s.Action {
+		if matchAction(a, r.Method, route) {
+			ok = true
+			break
+		}
+	}
+	if !ok {
+		return false
+	}
+	if len(s.Resource) > 0 {
+		ok = false
+		for _, res := range s.Resource {
+			if matchPath(res, r.Path) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return s.Condition == nil || s.Condition.match(r)
+}
+
+// match 返回请求是否满足全部条件
+func (c *Condition) match(r *PolicyRequest) bool {
+	if len(c.SourceIP) > 0 {
+		ok := false
+		for _, s := range c.SourceIP {
+			if n, err := parseCIDR(s); err == nil && r.SourceIP != nil && n.Contains(r.SourceIP) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if c.After != nil && !r.Time.After(*c.After) {
+		return false
+	}
+	if c.Before != nil && !r.Time.Before(*c.Before) {
+		return false
+	}
+	for name, patterns := range c.Params {
+		v, ok := r.Params[name]
+		if !ok {
+			return false
+		}
+		matched := false
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, v); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// matchAction 返回action是否匹配请求方法和路由, action为"*"或"方法 路由"
+func matchAction(action, method, route string) bool {
+	if action == "*" {
+		return true
+	}
+	i := strings.IndexByte(action, ' ')
+	if i < 0 {
+		return false
+	}
+	m, pattern := action[:i], strings.TrimSpace(action[i+1:])
+	if m != "*" && !strings.EqualFold(m, method) {
+		return false
+	}
+	return pattern == "*" || pattern == route || matchPath(pattern, route)
+}
+
+// parseCIDR 解析CIDR或单个IP
+func parseCIDR(s string) (*net.IPNet, error) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("无效的IP: %s", s)
+		}
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, n, err := net.ParseCIDR(s)
+	return n, err
+}
+
+// newPolicyRequest 由gin.Context构造策略评估的请求, ip为客户端IP
+func newPolicyRequest(c *gin.Context, ip net.IP) *PolicyRequest {
+	params := make(map[string]string, len(c.Params))
+	for _, p := range c.Params {
+		params[p.Key] = p.Value
+	}
+	return &PolicyRequest{
+		Method:   c.Request.Method,
+		Route:    c.FullPath(),
+		Path:     c.Request.URL.Path,
+		Params:   params,
+		SourceIP: ip,
+		Time:     time.Now(),
+	}
+}
@@ -0,0 +1,134 @@
+package ginaksk
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testPolicy = `{
+	"Version": "2026-10-18",
+	"Statement": [
+		{
+			"Sid": "ReadOrders",
+			"Effect": "Allow",
+			"Action": ["GET /tenants/:tenant/orders/:id", "GET /tenants/:tenant/orders"],
+			"Resource": "/tenants/*/orders/**",
+			"Condition": {"Params": {"tenant": ["acme", "acme-*"]}}
+		},
+		{
+			"Sid": "WriteFromOffice",
+			"Effect": "Allow",
+			"Action": "POST /tenants/:tenant/orders",
+			"Condition": {"SourceIp": ["10.0.0.0/8", "192.168.1.10"], "Before": "2099-01-01T00:00:00Z"}
+		},
+		{
+			"Sid": "DenyArchive",
+			"Effect": "Deny",
+			"Action": "* /tenants/:tenant/orders/:id",
+			"Resource": "/tenants/*/orders/archived-*"
+		}
+	]
+}`
+
+func TestPolicyEvaluate(t *testing.T) {
+	p, err := ParsePolicy([]byte(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	tests := []struct {
+		name    string
+		req     PolicyRequest
+		allowed bool
+		sid     string
+	}{
+		{
+			name:    "Read",
+			req:     PolicyRequest{Method: "GET", Route: "/tenants/:tenant/orders/:id", Path: "/tenants/acme/orders/1", Params: map[string]string{"tenant": "acme", "id": "1"}},
+			allowed: true,
+			sid:     "ReadOrders",
+		},
+		{
+			name: "ReadOtherTenant",
+			req:  PolicyRequest{Method: "GET", Route: "/tenants/:tenant/orders/:id", Path: "/tenants/other/orders/1", Params: map[string]string{"tenant": "other", "id": "1"}},
+		},
+		{
+			name: "ExplicitDeny",
+			req:  PolicyRequest{Method: "GET", Route: "/tenants/:tenant/orders/:id", Path: "/tenants/acme/orders/archived-1", Params: map[string]string{"tenant": "acme", "id": "archived-1"}},
+			sid:  "DenyArchive",
+		},
+		{
+			name:    "WriteFromOffice",
+			req:     PolicyRequest{Method: "POST", Route: "/tenants/:tenant/orders", Path: "/tenants/acme/orders", SourceIP: net.ParseIP("10.1.2.3"), Time: now},
+			allowed: true,
+			sid:     "WriteFromOffice",
+		},
+		{
+			name: "WriteFromHome",
+			req:  PolicyRequest{Method: "POST", Route: "/tenants/:tenant/orders", Path: "/tenants/acme/orders", SourceIP: net.ParseIP("8.8.8.8"), Time: now},
+		},
+		{
+			name: "Delete",
+			req:  PolicyRequest{Method: "DELETE", Route: "/tenants/:tenant/orders/:id", Path: "/tenants/acme/orders/1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := p.Evaluate(&tt.req)
+			if d.Allowed != tt.allowed || d.Sid != tt.sid {
+				t.Errorf("Evaluate() = %+v, want allowed %v sid %q", d, tt.allowed, tt.sid)
+			}
+		})
+	}
+}
+
+func TestParsePolicyInvalid(t *testing.T) {
+	for _, s := range []string{
+		`{"Statement": [{"Effect": "Maybe", "Action": "*"}]}`,
+		`{"Statement": [{"Effect": "Allow"}]}`,
+		`{"Statement": [{"Effect": "Allow", "Action": "*", "Condition": {"SourceIp": "10.0.0.0/33"}}]}`,
+		`{"Statement": [{"Effect": "Allow", "Action": "*", "Condition": {"IpAddress": {"aws:SourceIp": "10.0.0.0/8"}}}]}`,
+		`{"Statement": [{"Effect": "Allow", "Action": "*", "NotResource": "/admin/**"}]}`,
+		`{"Statement": [{"Effect": "Allow", "Action": "*"}], "Extra": 1}`,
+		`{"Statement": [{"Effect": "Allow", "Action": "*"}]} {}`,
+	} {
+		if _, err := ParsePolicy([]byte(s)); err == nil {
+			t.Errorf("ParsePolicy(%s) error = nil, want error", s)
+		}
+	}
+}
+
+func TestValidateWithPolicy(t *testing.T) {
+	t.Cleanup(cleanup)
+	gin.SetMode(gin.TestMode)
+	p, _ := ParsePolicy([]byte(testPolicy))
+	credFn := func(ak string) (*Credential, error) {
+		return &Credential{AccessKey: ak, SecretKey: "sk", Policy: p}, nil
+	}
+	e := gin.New()
+	e.Use(New(credFn))
+	e.GET("/tenants/:tenant/orders/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+	tests := []struct {
+		url  string
+		want int
+	}{
+		{url: "http://localhost/tenants/acme/orders/1", want: http.StatusOK},
+		{url: "http://localhost/tenants/other/orders/1", want: http.StatusForbidden},
+		{url: "http://localhost/tenants/acme/orders/archived-1", want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		f, _ := NewRequestFunc("ak", "sk")
+		req, _ := f(context.TODO(), "GET", tt.url, nil)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("GET %s status = %d, want %d", tt.url, w.Code, tt.want)
+		}
+	}
+}
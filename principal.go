@@ -0,0 +1,97 @@
+package ginaksk
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// SchemeSecretKey 使用secretKey签名
+	SchemeSecretKey = "secretkey"
+	// SchemeDerivedKey 使用DeriveSigningKey派生的签名密钥签名
+	SchemeDerivedKey = "derived"
+	// SchemeSession 使用临时会话凭证签名
+	SchemeSession = "session"
+)
+
+const (
+	// principalKey gin.Context中保存Principal的key
+	principalKey = "ginaksk.principal"
+	// anonymousKey gin.Context中标记匿名请求的key
+	anonymousKey = "ginaksk.anonymous"
+)
+
+// principalContextKey context.Context中保存Principal的key
+type principalContextKey struct{}
+
+// Principal 验证通过的身份
+type Principal struct {
+	// AccessKey 访问key
+	AccessKey string
+	// ParentAccessKey 临时会话凭证的签发者, 其他签名方式为空
+	ParentAccessKey string
+	// Credential 凭证, 不包含SecretKey
+	Credential *Credential
+	// Scopes 权限范围
+	Scopes []string
+	// Scheme 签名方式, SchemeSecretKey, SchemeDerivedKey或SchemeSession
+	Scheme string
+	// CredentialScope 派生签名密钥的范围, 只在Scheme为SchemeDerivedKey时有效
+	CredentialScope CredentialScope
+	// Skew 服务端时间减去请求时间戳的差
+	Skew time.Duration
+	// UnsignedPayload 请求内容未签名, 处理函数不能信任请求内容
+	UnsignedPayload bool
+}
+
+// newPrincipal 返回凭证对应的Principal
+func newPrincipal(cred *Credential) *Principal {
+	c := *cred
+	c.SecretKey = ""
+	return &Principal{
+		AccessKey:  c.AccessKey,
+		Credential: &c,
+		Scopes:     c.Scopes,
+		Scheme:     SchemeSecretKey,
+	}
+}
+
+// GetPrincipal 返回验证通过的身份
+func GetPrincipal(c *gin.Context) (*Principal, bool) {
+	if v, ok := c.Get(principalKey); ok {
+		p, ok := v.(*Principal)
+		return p, ok && p != nil
+	}
+	return nil, false
+}
+
+// PrincipalFromContext 返回ctx中验证通过的身份, 中间件验证通过后保存在c.Request.Context()中
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalContextKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// ContextWithPrincipal 返回保存了身份p的context.Context
+func ContextWithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, p)
+}
+
+// setPrincipal 在gin.Context和c.Request.Context()中保存验证通过的身份
+func setPrincipal(c *gin.Context, p *Principal) {
+	c.Set(principalKey, p)
+	c.Request = c.Request.WithContext(ContextWithPrincipal(c.Request.Context(), p))
+}
+
+// IsAnonymous 返回请求是否在WithOptionalAuth模式下没有携带凭证而匿名通过
+func IsAnonymous(c *gin.Context) bool {
+	return c.GetBool(anonymousKey)
+}
+
+// anonymous 返回请求是否没有携带任何凭证
+func anonymous(c *gin.Context) bool {
+	return c.GetHeader(headerAccessKey) == "" &&
+		c.GetHeader(headerSignature) == "" &&
+		c.GetHeader(headerSessionToken) == ""
+}
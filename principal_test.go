@@ -0,0 +1,126 @@
+package ginaksk
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPrincipal(t *testing.T) {
+	t.Cleanup(cleanup)
+	gin.SetMode(gin.TestMode)
+	issuer, _ := NewSessionIssuer([]byte("issuer-key"), time.Minute)
+	credFn := func(ak string) (*Credential, error) {
+		return &Credential{AccessKey: ak, SecretKey: "sk", Scopes: []string{"orders:read"}, Metadata: map[string]string{"tenant": "acme"}}, nil
+	}
+	var got, fromCtx *Principal
+	e := gin.New()
+	e.Use(New(credFn, WithDerivedKeys("orders"), WithSessionIssuer(issuer)))
+	e.GET("/e", func(c *gin.Context) {
+		got, _ = GetPrincipal(c)
+		fromCtx, _ = PrincipalFromContext(c.Request.Context())
+	})
+	scope := CredentialScope{Date: time.Now(), Service: "orders", Purpose: "api"}
+	parent, _ := credFn("ak")
+	session, _ := issuer.Issue(parent, "orders:read")
+	tests := []struct {
+		name       string
+		ak, sk     string
+		opts       []RequestOption
+		wantScheme string
+		wantScopes []string
+		wantParent string
+	}{
+		{name: "SecretKey", ak: "ak", sk: "sk", wantScheme: SchemeSecretKey, wantScopes: []string{"orders:read"}},
+		{name: "DerivedKey", ak: "ak", sk: DeriveSigningKey("sk", scope), opts: []RequestOption{WithCredentialScope(scope)}, wantScheme: SchemeDerivedKey, wantScopes: []string{"orders:read"}},
+		{name: "Session", ak: session.AccessKey, sk: session.SecretKey, opts: []RequestOption{WithSessionToken(session.SessionToken)}, wantScheme: SchemeSession, wantScopes: []string{"orders:read"}, wantParent: "ak"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, fromCtx = nil, nil
+			f, _ := NewRequestFunc(tt.ak, tt.sk, tt.opts...)
+			req, _ := f(context.TODO(), "GET", `http://localhost/e`, nil)
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, req)
+			if w.Code != http.StatusOK || got == nil {
+				t.Fatalf("status = %d, principal = %v", w.Code, got)
+			}
+			if got != fromCtx {
+				t.Errorf("PrincipalFromContext() = %v, want %v", fromCtx, got)
+			}
+			if got.AccessKey != tt.ak || got.Scheme != tt.wantScheme || got.ParentAccessKey != tt.wantParent {
+				t.Errorf("Principal = %+v", got)
+			}
+			if len(got.Scopes) != 1 || got.Scopes[0] != tt.wantScopes[0] {
+				t.Errorf("Scopes = %v, want %v", got.Scopes, tt.wantScopes)
+			}
+			if got.Credential.SecretKey != "" {
+				t.Error("Principal.Credential contains SecretKey")
+			}
+			if got.Skew < 0 || got.Skew > time.Minute {
+				t.Errorf("Skew = %s", got.Skew)
+			}
+		})
+	}
+}
+
+func TestOptionalAuth(t *testing.T) {
+	t.Cleanup(cleanup)
+	gin.SetMode(gin.TestMode)
+	credFn := func(ak string) (*Credential, error) {
+		return &Credential{AccessKey: ak, SecretKey: "sk"}, nil
+	}
+	e := gin.New()
+	e.Use(New(credFn, WithOptionalAuth(), WithScopeRules(ScopeRule{Path: "/private", Scopes: []string{"private"}})))
+	e.GET("/public", func(c *gin.Context) {
+		if p, ok := GetPrincipal(c); ok {
+			c.String(http.StatusOK, p.AccessKey)
+			return
+		}
+		if IsAnonymous(c) {
+			c.String(http.StatusOK, "anonymous")
+		}
+	})
+	e.GET("/private", func(c *gin.Context) { c.Status(http.StatusOK) })
+	signed := func(sk, path string) *http.Request {
+		f, _ := NewRequestFunc("ak", sk)
+		req, _ := f(context.TODO(), "GET", `http://localhost`+path, nil)
+		return req
+	}
+	tests := []struct {
+		name     string
+		req      *http.Request
+		want     int
+		wantBody string
+	}{
+		{name: "Anonymous", req: httptest.NewRequest("GET", "/public", nil), want: http.StatusOK, wantBody: "anonymous"},
+		{name: "Authenticated", req: signed("sk", "/public"), want: http.StatusOK, wantBody: "ak"},
+		{name: "InvalidCredential", req: signed("other", "/public"), want: http.StatusUnauthorized},
+		{
+			name: "SignatureWithoutAccessKey",
+			req: func() *http.Request {
+				req := signed("sk", "/public")
+				req.Header.Del(headerAccessKey)
+				return req
+			}(),
+			want: http.StatusUnauthorized,
+		},
+		{name: "AnonymousPrivate", req: httptest.NewRequest("GET", "/private", nil), want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, tt.req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("body = %s, want %s", w.Body, tt.wantBody)
+			}
+		})
+	}
+}
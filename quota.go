@@ -0,0 +1,290 @@
+package ginaksk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrQuotaExceeded 请求次数超过配额
+var ErrQuotaExceeded = newStatusError(http.StatusTooManyRequests, "请求次数超过配额")
+
+const (
+	// quotaDayLayout 每日配额的周期格式, 使用UTC时间
+	quotaDayLayout = "2006-01-02"
+	// quotaMonthLayout 每月配额的周期格式, 使用UTC时间
+	quotaMonthLayout = "2006-01"
+)
+
+// Quota 请求配额, 为0时不限制
+type Quota struct {
+	// Daily 每日的请求次数
+	Daily int64
+	// Monthly 每月的请求次数
+	Monthly int64
+}
+
+// QuotaUsage accessKey当前周期的请求次数
+type QuotaUsage struct {
+	// AccessKey 访问key
+	AccessKey string `json:"access_key"`
+	// Day 日期, 如2026-10-18
+	Day string `json:"day"`
+	// Daily 当日的请求次数
+	Daily int64 `json:"daily"`
+	// Month 月份, 如2026-10
+	Month string `json:"month"`
+	// Monthly 当月的请求次数
+	Monthly int64 `json:"monthly"`
+}
+
+// QuotaStore 保存配额计数, 多个实例共享计数时可以实现为Redis等存储
+type QuotaStore interface {
+	// Add 将key的计数增加delta, 返回增加后的计数
+	Add(key string, delta int64) (int64, error)
+	// Get 返回key的计数
+	Get(key string) (int64, error)
+}
+
+const (
+	// quotaPruneInterval 内存中删除已经结束的周期的间隔
+	quotaPruneInterval = time.Hour
+	// quotaFlushInterval 配额计数写入文件的间隔
+	quotaFlushInterval = time.Second
+)
+
+// memoryQuotaStore 内存中的配额计数
+type memoryQuotaStore struct {
+	mu        sync.Mutex
+	counts    map[string]int64
+	lastPrune time.Time
+}
+
+// NewMemoryQuotaStore 返回一个内存中的QuotaStore, 重启后计数清零, 已经结束的周期定期删除
+func NewMemoryQuotaStore() QuotaStore {
+	return &memoryQuotaStore{counts: make(map[string]int64)}
+}
+
+// Add 将key的计数增加delta
+func (s *memoryQuotaStore) Add(key string, delta int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now := time.Now(); now.Sub(s.lastPrune) >= quotaPruneInterval {
+		s.prune(now)
+	}
+	s.counts[key] += delta
+	return s.counts[key], nil
+}
+
+// Get 返回key的计数
+func (s *memoryQuotaStore) Get(key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counts[key], nil
+}
+
+// prune 删除now之前已经结束的周期的计数, 返回是否删除了计数, 调用者必须持有锁
+func (s *memoryQuotaStore) prune(now time.Time) bool {
+	s.lastPrune = now
+	day, month := now.UTC().Format(quotaDayLayout), now.UTC().Format(quotaMonthLayout)
+	pruned := false
+	for key := range s.counts {
+		i := strings.LastIndexByte(key, '|')
+		if i < 0 {
+			continue
+		}
+		kind, period := key[:i], key[i+1:]
+		if (strings.HasSuffix(kind, "|day") && period < day) || (strings.HasSuffix(kind, "|month") && period < month) {
+			delete(s.counts, key)
+			pruned = true
+		}
+	}
+	return pruned
+}
+
+// FileQuotaStore 保存在JSON文件中的配额计数, 只适合单个实例使用;
+// 计数在内存中更新, 后台每秒删除已经结束的周期并写入文件, 停止服务前调用Close写入最后的计数
+type FileQuotaStore struct {
+	memoryQuotaStore
+	name  string
+	dirty bool
+	// flushMu 保证同一时间只有一次写入
+	flushMu   sync.Mutex
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewFileQuotaStore 返回一个保存在JSON文件name中的QuotaStore, 文件不存在时创建
+func NewFileQuotaStore(name string) (*FileQuotaStore, error) {
+	s := &FileQuotaStore{memoryQuotaStore: memoryQuotaStore{counts: make(map[string]int64)}, name: name, done: make(chan struct{})}
+	b, err := ioutil.ReadFile(name)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("读取配额文件发生错误: %w", err)
+	}
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, &s.counts); err != nil {
+			return nil, fmt.Errorf("解析配额文件发生错误: %w", err)
+		}
+	}
+	s.dirty = s.prune(time.Now())
+	go s.loop()
+	return s, nil
+}
+
+// Add 将key的计数增加delta, 由后台写入文件
+func (s *FileQuotaStore) Add(key string, delta int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counts[key] += delta
+	s.dirty = true
+	return s.counts[key], nil
+}
+
+// loop 定期写入文件, 直到Close
+func (s *FileQuotaStore) loop() {
+	t := time.NewTicker(quotaFlushInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if err := s.Flush(); err != nil {
+				logger.Printf("%s", err)
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Flush 删除已经结束的周期, 计数有变化时写入文件
+func (s *FileQuotaStore) Flush() error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+	s.mu.Lock()
+	if s.prune(time.Now()) {
+		s.dirty = true
+	}
+	if !s.dirty {
+		s.mu.Unlock()
+		return nil
+	}
+	b, err := json.Marshal(s.counts)
+	s.dirty = false
+	s.mu.Unlock()
+	if err == nil {
+		err = s.write(b)
+	}
+	if err != nil {
+		// 下次重新写入
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+	}
+	return err
+}
+
+// write 通过临时文件写入b
+func (s *FileQuotaStore) write(b []byte) error {
+	tmp := s.name + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return fmt.Errorf("写入配额文件发生错误: %w", err)
+	}
+	if err := os.Rename(tmp, s.name); err != nil {
+		return fmt.Errorf("写入配额文件发生错误: %w", err)
+	}
+	return nil
+}
+
+// Close 停止后台写入, 并将计数写入文件
+func (s *FileQuotaStore) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+	return s.Flush()
+}
+
+// QuotaCounter 按照验证通过的accessKey统计每日和每月的请求次数, 临时会话凭证计入签发者的accessKey
+type QuotaCounter struct {
+	store QuotaStore
+	quota Quota
+}
+
+// NewQuotaCounter 返回一个QuotaCounter, store为nil时使用内存存储; quota为默认的配额, 凭证的Quota不为nil时使用凭证的配额
+func NewQuotaCounter(store QuotaStore, quota Quota) *QuotaCounter {
+	if store == nil {
+		store = NewMemoryQuotaStore()
+	}
+	return &QuotaCounter{store: store, quota: quota}
+}
+
+// quotaKeys 返回accessKey在t所在周期的每日和每月计数的key
+func quotaKeys(accessKey string, t time.Time) (day, month string) {
+	t = t.UTC()
+	return accessKey + "|day|" + t.Format(quotaDayLayout), accessKey + "|month|" + t.Format(quotaMonthLayout)
+}
+
+// Usage 返回accessKey在t所在周期的请求次数
+func (q *QuotaCounter) Usage(accessKey string, t time.Time) (QuotaUsage, error) {
+	day, month := quotaKeys(accessKey, t)
+	u := QuotaUsage{
+		AccessKey: accessKey,
+		Day:       t.UTC().Format(quotaDayLayout),
+		Month:     t.UTC().Format(quotaMonthLayout),
+	}
+	var err error
+	if u.Daily, err = q.store.Get(day); err != nil {
+		return u, err
+	}
+	if u.Monthly, err = q.store.Get(month); err != nil {
+		return u, err
+	}
+	return u, nil
+}
+
+// take 计数一次请求, 超过配额时撤销计数并返回ErrQuotaExceeded
+func (q *QuotaCounter) take(accessKey string, quota Quota, t time.Time) error {
+	day, month := quotaKeys(accessKey, t)
+	daily, err := q.store.Add(day, 1)
+	if err != nil {
+		return err
+	}
+	monthly, err := q.store.Add(month, 1)
+	if err != nil {
+		q.store.Add(day, -1)
+		return err
+	}
+	if (quota.Daily > 0 && daily > quota.Daily) || (quota.Monthly > 0 && monthly > quota.Monthly) {
+		q.store.Add(day, -1)
+		q.store.Add(month, -1)
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// Middleware 返回一个统计请求次数的gin中间件, 必须在Validate或New返回的中间件之后使用;
+// 超过配额时返回ErrQuotaExceeded, 存储发生错误时记录日志并放行请求
+func (q *QuotaCounter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cred, ak := limitKey(c)
+		if cred == nil {
+			return
+		}
+		quota := q.quota
+		if cred.Quota != nil {
+			quota = *cred.Quota
+		}
+		err := q.take(ak, quota, time.Now())
+		if err == ErrQuotaExceeded {
+			abort(c, err)
+		} else if err != nil {
+			logger.Printf("统计请求次数发生错误 accesskey: %s, %s", ak, err)
+		}
+	}
+}
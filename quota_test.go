@@ -0,0 +1,98 @@
+package ginaksk
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestQuotaCounter(t *testing.T) {
+	t.Cleanup(cleanup)
+	gin.SetMode(gin.TestMode)
+	name := filepath.Join(t.TempDir(), "quota.json")
+	store, err := NewFileQuotaStore(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credFn := func(ak string) (*Credential, error) {
+		cred := &Credential{AccessKey: ak, SecretKey: "sk"}
+		if ak == "partner" {
+			cred.Quota = &Quota{Daily: 1}
+		}
+		return cred, nil
+	}
+	q := NewQuotaCounter(store, Quota{Daily: 2, Monthly: 100})
+	e := gin.New()
+	e.Use(New(credFn), q.Middleware())
+	e.GET("/e", func(c *gin.Context) { c.Status(http.StatusOK) })
+	tests := []struct {
+		ak   string
+		want int
+	}{
+		{ak: "partner", want: http.StatusOK},
+		{ak: "partner", want: http.StatusTooManyRequests},
+		{ak: "default", want: http.StatusOK},
+		{ak: "default", want: http.StatusOK},
+		{ak: "default", want: http.StatusTooManyRequests},
+	}
+	for i, tt := range tests {
+		f, _ := NewRequestFunc(tt.ak, "sk")
+		req, _ := f(context.TODO(), "GET", `http://localhost/e`, nil)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%d %s: status = %d, want %d", i, tt.ak, w.Code, tt.want)
+		}
+	}
+
+	// 重新加载文件后计数不变, 被拒绝的请求不计数
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	store, err = NewFileQuotaStore(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := NewQuotaCounter(store, Quota{}).Usage("default", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Daily != 2 || u.Monthly != 2 {
+		t.Errorf("Usage() = %+v, want daily 2 monthly 2", u)
+	}
+	store.Close()
+}
+
+func TestFileQuotaStorePrune(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "quota.json")
+	now := time.Now()
+	day, month := quotaKeys("ak", now)
+	oldDay, oldMonth := quotaKeys("ak", now.AddDate(0, -1, -1))
+	b, _ := json.Marshal(map[string]int64{day: 1, month: 2, oldDay: 3, oldMonth: 4})
+	if err := ioutil.WriteFile(name, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewFileQuotaStore(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Add(day, 1)
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]int64
+	b, _ = ioutil.ReadFile(name)
+	json.Unmarshal(b, &got)
+	want := map[string]int64{day: 2, month: 2}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("文件中的计数 = %v, want %v", got, want)
+	}
+}
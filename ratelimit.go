@@ -0,0 +1,171 @@
+package ginaksk
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrRateLimited 请求过于频繁
+var ErrRateLimited = newStatusError(http.StatusTooManyRequests, "请求过于频繁")
+
+// Limit 令牌桶的速率限制
+type Limit struct {
+	// Rate 每秒补充的令牌数, 小于等于0时不限制
+	Rate float64
+	// Burst 令牌桶的容量, 小于1时为1
+	Burst int
+}
+
+// burst 返回令牌桶的容量
+func (l Limit) burst() int {
+	if l.Burst < 1 {
+		return 1
+	}
+	return l.Burst
+}
+
+// RateLimitResult 取令牌的结果
+type RateLimitResult struct {
+	// Allowed 是否取到令牌
+	Allowed bool
+	// Remaining 剩余的令牌数
+	Remaining int
+	// Reset 令牌桶填满需要的时间
+	Reset time.Duration
+	// RetryAfter 没有取到令牌时, 下一个令牌可用需要等待的时间
+	RetryAfter time.Duration
+}
+
+// RateLimitStore 保存令牌桶的状态, 多个实例共享限制时可以实现为Redis等存储
+type RateLimitStore interface {
+	// Take 从key对应的令牌桶中取出一个令牌
+	Take(key string, limit Limit, now time.Time) (RateLimitResult, error)
+}
+
+// sweepInterval 清理已经填满的令牌桶的间隔
+const sweepInterval = time.Minute
+
+// bucket 令牌桶
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  Limit
+}
+
+// full 返回令牌桶在now时是否已经填满, 填满的令牌桶与新建的令牌桶相同, 可以删除
+func (b *bucket) full(now time.Time) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*b.limit.Rate >= float64(b.limit.burst())
+}
+
+// memoryRateLimitStore 内存中的令牌桶
+type memoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewMemoryRateLimitStore 返回一个内存中的RateLimitStore, 每个最近访问过的accessKey占用一个令牌桶, 已经填满的令牌桶定期删除
+func NewMemoryRateLimitStore() RateLimitStore {
+	return &memoryRateLimitStore{buckets: make(map[string]*bucket)}
+}
+
+// sweep 删除已经填满的令牌桶, 每个sweepInterval最多执行一次
+func (s *memoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if b.full(now) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// Take 从key对应的令牌桶中取出一个令牌
+func (s *memoryRateLimitStore) Take(key string, limit Limit, now time.Time) (RateLimitResult, error) {
+	burst := float64(limit.burst())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(now)
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		s.buckets[key] = b
+	}
+	b.limit = limit
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(burst, b.tokens+elapsed*limit.Rate)
+		b.last = now
+	}
+	var r RateLimitResult
+	if b.tokens >= 1 {
+		b.tokens--
+		r.Allowed = true
+	} else {
+		r.RetryAfter = seconds((1 - b.tokens) / limit.Rate)
+	}
+	r.Remaining = int(b.tokens)
+	r.Reset = seconds((burst - b.tokens) / limit.Rate)
+	return r, nil
+}
+
+// seconds 将秒数转换为time.Duration
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// RateLimiter 按照验证通过的accessKey限制请求速率, 临时会话凭证计入签发者的accessKey
+type RateLimiter struct {
+	store RateLimitStore
+	limit Limit
+}
+
+// NewRateLimiter 返回一个RateLimiter, store为nil时使用内存存储; limit为默认的限制, 凭证的RateLimit不为nil时使用凭证的限制
+func NewRateLimiter(store RateLimitStore, limit Limit) *RateLimiter {
+	if store == nil {
+		store = NewMemoryRateLimitStore()
+	}
+	return &RateLimiter{store: store, limit: limit}
+}
+
+// Middleware 返回一个限制请求速率的gin中间件, 必须在Validate或New返回的中间件之后使用;
+// 响应中设置RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset头部, 超过限制时设置Retry-After并返回ErrRateLimited;
+// 存储发生错误时记录日志并放行请求
+func (l *RateLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cred, ak := limitKey(c)
+		if cred == nil {
+			return
+		}
+		limit := l.limit
+		if cred.RateLimit != nil {
+			limit = *cred.RateLimit
+		}
+		if limit.Rate <= 0 {
+			return
+		}
+		r, err := l.store.Take(ak, limit, time.Now())
+		if err != nil {
+			logger.Printf("限制请求速率发生错误 accesskey: %s, %s", ak, err)
+			return
+		}
+		c.Header("RateLimit-Limit", strconv.Itoa(limit.burst()))
+		c.Header("RateLimit-Remaining", strconv.Itoa(r.Remaining))
+		c.Header("RateLimit-Reset", ceilSeconds(r.Reset))
+		if !r.Allowed {
+			c.Header("Retry-After", ceilSeconds(r.RetryAfter))
+			abort(c, ErrRateLimited)
+		}
+	}
+}
+
+// ceilSeconds 返回向上取整的秒数
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
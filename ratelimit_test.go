@@ -0,0 +1,173 @@
+package ginaksk
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	s := NewMemoryRateLimitStore()
+	limit := Limit{Rate: 1, Burst: 2}
+	now := time.Now()
+	tests := []struct {
+		name          string
+		after         time.Duration
+		wantAllowed   bool
+		wantRemaining int
+	}{
+		{name: "First", wantAllowed: true, wantRemaining: 1},
+		{name: "Second", wantAllowed: true, wantRemaining: 0},
+		{name: "Exhausted", wantAllowed: false, wantRemaining: 0},
+		{name: "Refilled", after: time.Second, wantAllowed: true, wantRemaining: 0},
+		{name: "Full", after: 10 * time.Second, wantAllowed: true, wantRemaining: 1},
+	}
+	for _, tt := range tests {
+		now = now.Add(tt.after)
+		r, err := s.Take("ak", limit, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Allowed != tt.wantAllowed || r.Remaining != tt.wantRemaining {
+			t.Errorf("%s: Take() = %+v, want allowed %v remaining %d", tt.name, r, tt.wantAllowed, tt.wantRemaining)
+		}
+		if !r.Allowed && r.RetryAfter != time.Second {
+			t.Errorf("%s: RetryAfter = %s, want 1s", tt.name, r.RetryAfter)
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	t.Cleanup(cleanup)
+	gin.SetMode(gin.TestMode)
+	credFn := func(ak string) (*Credential, error) {
+		cred := &Credential{AccessKey: ak, SecretKey: "sk"}
+		if ak == "partner" {
+			cred.RateLimit = &Limit{Rate: 0.1, Burst: 1}
+		}
+		return cred, nil
+	}
+	e := gin.New()
+	e.Use(New(credFn), NewRateLimiter(nil, Limit{Rate: 0.1, Burst: 2}).Middleware())
+	e.GET("/e", func(c *gin.Context) { c.Status(http.StatusOK) })
+	tests := []struct {
+		ak   string
+		want int
+	}{
+		{ak: "partner", want: http.StatusOK},
+		{ak: "partner", want: http.StatusTooManyRequests},
+		{ak: "default", want: http.StatusOK},
+		{ak: "default", want: http.StatusOK},
+		{ak: "default", want: http.StatusTooManyRequests},
+	}
+	for i, tt := range tests {
+		f, _ := NewRequestFunc(tt.ak, "sk")
+		req, _ := f(context.TODO(), "GET", `http://localhost/e`, nil)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%d %s: status = %d, want %d", i, tt.ak, w.Code, tt.want)
+		}
+		if w.Header().Get("RateLimit-Limit") == "" {
+			t.Errorf("%d %s: missing RateLimit-Limit", i, tt.ak)
+		}
+		if tt.want == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "10" {
+			t.Errorf("%d %s: Retry-After = %q, want 10", i, tt.ak, w.Header().Get("Retry-After"))
+		}
+	}
+}
+
+func TestLimitsCountSessionParent(t *testing.T) {
+	t.Cleanup(cleanup)
+	gin.SetMode(gin.TestMode)
+	issuer, _ := NewSessionIssuer([]byte("issuer-key"), time.Minute)
+	parent := &Credential{AccessKey: "partner", SecretKey: "sk", RateLimit: &Limit{Rate: 0.1, Burst: 2}, Quota: &Quota{Daily: 1}}
+	credFn := func(ak string) (*Credential, error) {
+		if ak == parent.AccessKey {
+			return parent, nil
+		}
+		return nil, nil
+	}
+	quotas := NewQuotaCounter(nil, Quota{})
+	tests := []struct {
+		name       string
+		middleware gin.HandlerFunc
+		want       []int
+	}{
+		// 默认限制不限制, 只有签发者的限制生效
+		{name: "RateLimit", middleware: NewRateLimiter(nil, Limit{}).Middleware(), want: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}},
+		{name: "Quota", middleware: quotas.Middleware(), want: []int{http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := gin.New()
+			e.Use(New(credFn, WithSessionIssuer(issuer)), tt.middleware)
+			e.GET("/e", func(c *gin.Context) { c.Status(http.StatusOK) })
+			for i, want := range tt.want {
+				// 每个请求使用新的临时会话凭证
+				session, _ := issuer.Issue(parent)
+				f, _ := NewRequestFunc(session.AccessKey, session.SecretKey, WithSessionToken(session.SessionToken))
+				req, _ := f(context.TODO(), "GET", `http://localhost/e`, nil)
+				w := httptest.NewRecorder()
+				e.ServeHTTP(w, req)
+				if w.Code != want {
+					t.Errorf("%d: status = %d, want %d", i, w.Code, want)
+				}
+			}
+		})
+	}
+	if u, _ := quotas.Usage("partner", time.Now()); u.Daily != 1 {
+		t.Errorf("Usage(partner).Daily = %d, want 1", u.Daily)
+	}
+
+	// 同一个签发者的不同临时会话凭证共享并发限制
+	limiter := NewConcurrencyLimiter(0)
+	parent.MaxConcurrent = 1
+	e := gin.New()
+	e.Use(New(credFn, WithSessionIssuer(issuer)), limiter.Middleware())
+	var inner int
+	e.GET("/e", func(c *gin.Context) {
+		inner = limiter.InFlight("partner")
+		session, _ := issuer.Issue(parent)
+		f, _ := NewRequestFunc(session.AccessKey, session.SecretKey, WithSessionToken(session.SessionToken))
+		req, _ := f(context.TODO(), "GET", `http://localhost/nested`, nil)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		c.Status(w.Code)
+	})
+	e.GET("/nested", func(c *gin.Context) { c.Status(http.StatusOK) })
+	session, _ := issuer.Issue(parent)
+	f, _ := NewRequestFunc(session.AccessKey, session.SecretKey, WithSessionToken(session.SessionToken))
+	req, _ := f(context.TODO(), "GET", `http://localhost/e`, nil)
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	if inner != 1 || w.Code != http.StatusTooManyRequests {
+		t.Errorf("InFlight(partner) = %d, nested status = %d, want 1, %d", inner, w.Code, http.StatusTooManyRequests)
+	}
+}
+
+func TestMemoryRateLimitStoreSweep(t *testing.T) {
+	s := NewMemoryRateLimitStore().(*memoryRateLimitStore)
+	limit := Limit{Rate: 1, Burst: 10}
+	now := time.Now()
+	s.Take("idle", limit, now)
+	for i := 0; i < 100; i++ {
+		s.Take("busy", limit, now.Add(sweepInterval))
+	}
+	now = now.Add(sweepInterval + time.Second)
+	s.Take("new", limit, now)
+	if _, ok := s.buckets["idle"]; ok {
+		t.Error("填满的令牌桶没有删除")
+	}
+	if _, ok := s.buckets["busy"]; !ok {
+		t.Error("没有填满的令牌桶被删除")
+	}
+	// 删除后重新创建的令牌桶是满的
+	if r, _ := s.Take("idle", limit, now); r.Remaining != limit.Burst-1 {
+		t.Errorf("Remaining = %d, want %d", r.Remaining, limit.Burst-1)
+	}
+}
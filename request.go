@@ -3,9 +3,7 @@ package ginaksk
 import (
 	"bytes"
 	"context"
-	"crypto/rand"
 	"fmt"
-	"io"
 	"net/http"
 	"strconv"
 	"time"
@@ -21,50 +19,192 @@ var (
 	ErrSecretKeyEmpty = newError("accesskey无效")
 )
 
-// NewRequestFunc 返回一个RequestFunc
-func NewRequestFunc(ak, sk string) (RequestFunc, error) {
+// RequestOption RequestFunc的可选配置
+type RequestOption func(o *requestOptions)
+
+// requestOptions 请求构造函数的配置项
+type requestOptions struct {
+	// scope 派生签名密钥的范围
+	scope string
+	// token 临时会话凭证的令牌
+	token string
+	// canonical 请求内容的规范化方式
+	canonical BodyCanonicalization
+	// contentType 请求的Content-Type
+	contentType string
+	// strict 总是发送x-auth-body-hash
+	strict bool
+	// unsigned 不签名请求内容
+	unsigned bool
+	// digests Content-Digest使用的摘要算法
+	digests []string
+}
+
+// WithCredentialScope 声明派生签名密钥的范围, 此时NewRequestFunc的sk应为DeriveSigningKey返回的签名密钥
+func WithCredentialScope(scope CredentialScope) RequestOption {
+	return func(o *requestOptions) {
+		o.scope = scope.String()
+	}
+}
+
+// WithSessionToken 发送临时会话凭证的令牌, 此时NewRequestFunc的ak和sk应为SessionCredential中的临时accessKey和secretKey
+func WithSessionToken(token string) RequestOption {
+	return func(o *requestOptions) {
+		o.token = token
+	}
+}
+
+// WithRequestBodyCanonicalization 设置计算请求内容哈希值前的规范化方式, 默认为BodyExact, 发送的请求内容不变;
+// 必须与服务端的WithBodyCanonicalization一致; 使用BodyCanonicalJSON且没有使用WithContentType时, Content-Type为application/json
+func WithRequestBodyCanonicalization(m BodyCanonicalization) RequestOption {
+	return func(o *requestOptions) {
+		o.canonical = m
+	}
+}
+
+// WithContentType 设置请求的Content-Type, BodyCanonicalJSON根据Content-Type决定是否规范化JSON
+func WithContentType(contentType string) RequestOption {
+	return func(o *requestOptions) {
+		o.contentType = contentType
+	}
+}
+
+// WithRequestStrictBodyHash 总是发送x-auth-body-hash, 空的请求内容使用空字符串的哈希值, 用于开启了WithStrictBodyHash的服务端
+func WithRequestStrictBodyHash() RequestOption {
+	return func(o *requestOptions) {
+		o.strict = true
+	}
+}
+
+// newRequestOptions 校验ak, sk并返回请求构造函数的配置项
+func newRequestOptions(ak, sk string, opts []RequestOption) (*requestOptions, error) {
 	if ak == "" {
 		return nil, ErrAccessKeyEmpty
 	}
 	if sk == "" {
 		return nil, ErrSecretKeyEmpty
 	}
+	var o requestOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	if o.canonical == BodyCanonicalJSON && o.contentType == "" {
+		o.contentType = "application/json"
+	}
+	for _, alg := range o.digests {
+		if _, ok := digestAlgorithms[alg]; !ok {
+			return nil, fmt.Errorf("不支持的摘要算法: %s", alg)
+		}
+	}
+	return &o, nil
+}
+
+// WithRequestUnsignedPayload 发送x-auth-body-hash: UNSIGNED-PAYLOAD, 不计算请求内容的哈希值,
+// 用于服务端使用WithUnsignedPayloadPaths或者凭证允许未签名请求内容的大文件传输
+func WithRequestUnsignedPayload() RequestOption {
+	return func(o *requestOptions) {
+		o.unsigned = true
+	}
+}
+
+// WithContentDigest 发送RFC 9530的Content-Digest和x-auth-body-hash: CONTENT-DIGEST, algs为摘要算法, 支持sha-256和sha-512, 为空时使用sha-256;
+// Content-Digest按照原始字节计算, 字段的值参与签名, 不使用WithRequestBodyCanonicalization
+func WithContentDigest(algs ...string) RequestOption {
+	return func(o *requestOptions) {
+		if len(algs) == 0 {
+			algs = []string{"sha-256"}
+		}
+		o.digests = algs
+	}
+}
+
+// NewRequestFunc 返回一个RequestFunc, opts为可选配置
+func NewRequestFunc(ak, sk string, opts ...RequestOption) (RequestFunc, error) {
+	o, err := newRequestOptions(ak, sk, opts)
+	if err != nil {
+		return nil, err
+	}
 	fn := func(ctx context.Context, method, url string, body []byte) (*http.Request, error) {
 		req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
 		if err != nil {
 			return nil, fmt.Errorf("创建HTTP请求发生错误:%w", err)
 		}
-
-		// 随机字符串
-		b := make([]byte, 6)
-		if _, err := io.ReadFull(rand.Reader, b); err != nil {
-			return nil, fmt.Errorf("读取随机字符串发生错误:%w", err)
+		cb, err := o.canonical.canonicalize(body, o.contentType)
+		if err != nil {
+			return nil, err
 		}
-		randomstr := encoder.EncodeToString(b)
-
-		ss := make([]string, 0, 4)
-		ss = append(ss, ak, randomstr)
-		// ak头部
-		req.Header.Set(headerAccessKey, ak)
-		// randomstr头部
-		req.Header.Set(headerRandomStr, randomstr)
-
-		ts := strconv.FormatInt(time.Now().Unix(), 10)
-		ss = append(ss, ts)
-		// 时间戳头部
-		req.Header.Set(headerTimestamp, ts)
-
-		if len(body) > 0 {
-			bodyhash := encoder.EncodeToString(hashSum(body))
-			ss = append(ss, bodyhash)
-			// body的hash头部
-			req.Header.Set(headerBodyHash, bodyhash)
+		var bodyhash string
+		switch {
+		case o.unsigned:
+			bodyhash = UnsignedPayload
+		case len(o.digests) > 0:
+			d, err := formatDigest(body, o.digests)
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set(headerContentDigest, d)
+			bodyhash = ContentDigestPayload
+		case len(cb) > 0 || o.strict:
+			bodyhash = encoder.EncodeToString(hashSum(cb))
+		}
+		if _, err := o.sign(req, ak, sk, bodyhash); err != nil {
+			return nil, err
 		}
-
-		// 签名头部
-		b = hmacSum([]byte(sk), ss...)
-		req.Header.Set(headerSignature, encoder.EncodeToString(b))
 		return req, nil
 	}
 	return fn, nil
 }
+
+// sign 设置请求的签名头部, bodyhash为空时不发送x-auth-body-hash, 为ContentDigestPayload时已经设置的摘要字段参与签名; 返回x-auth-signature
+func (o *requestOptions) sign(req *http.Request, ak, sk, bodyhash string) (string, error) {
+	// 随机字符串
+	b, err := randomBytes(6)
+	if err != nil {
+		return "", err
+	}
+	randomstr := encoder.EncodeToString(b)
+
+	ss := make([]string, 0, 6)
+	ss = append(ss, ak, randomstr)
+	// ak头部
+	req.Header.Set(headerAccessKey, ak)
+	// randomstr头部
+	req.Header.Set(headerRandomStr, randomstr)
+
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	ss = append(ss, ts)
+	// 时间戳头部
+	req.Header.Set(headerTimestamp, ts)
+
+	if o.contentType != "" {
+		req.Header.Set("Content-Type", o.contentType)
+	}
+	if bodyhash != "" {
+		ss = append(ss, bodyhash)
+		// body的hash头部
+		req.Header.Set(headerBodyHash, bodyhash)
+	}
+	if bodyhash == ContentDigestPayload {
+		// 摘要字段参与签名
+		ss = append(ss, digestFields(req)...)
+	}
+
+	if o.scope != "" {
+		ss = append(ss, o.scope)
+		// 派生签名密钥的范围头部
+		req.Header.Set(headerCredentialScope, o.scope)
+	}
+
+	if o.token != "" {
+		ss = append(ss, o.token)
+		// 会话令牌头部
+		req.Header.Set(headerSessionToken, o.token)
+	}
+
+	// 签名头部
+	signature := encoder.EncodeToString(hmacSum([]byte(sk), ss...))
+	req.Header.Set(headerSignature, signature)
+	return signature, nil
+}
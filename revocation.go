@@ -0,0 +1,144 @@
+package ginaksk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrAccessKeyRevoked accessKey已吊销
+var ErrAccessKeyRevoked = newError("accesskey已吊销")
+
+// RevocationList 吊销列表, 中间件在查询secretKey前检查, 运行时的修改立即生效, 可以并发使用
+//
+// 可以吊销指定的accessKey、指定前缀的accessKey, 或者吊销某个accessKey在指定时间前
+// 派生的签名密钥(按照范围中的日期)和签发的临时会话凭证
+type RevocationList struct {
+	mu           sync.RWMutex
+	accessKeys   map[string]bool
+	prefixes     []string
+	issuedBefore map[string]time.Time
+}
+
+// revocationFile 吊销列表文件的格式
+type revocationFile struct {
+	// AccessKeys 吊销的accessKey
+	AccessKeys []string `json:"access_keys"`
+	// Prefixes 吊销的accessKey前缀
+	Prefixes []string `json:"prefixes"`
+	// IssuedBefore accessKey在指定时间前派生或签发的凭证都被吊销
+	IssuedBefore map[string]time.Time `json:"issued_before"`
+}
+
+// NewRevocationList 返回一个空的吊销列表
+func NewRevocationList() *RevocationList {
+	return &RevocationList{
+		accessKeys:   make(map[string]bool),
+		issuedBefore: make(map[string]time.Time),
+	}
+}
+
+// LoadRevocationList 从JSON文件name中加载吊销列表
+func LoadRevocationList(name string) (*RevocationList, error) {
+	l := NewRevocationList()
+	if err := l.LoadFile(name); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// LoadFile 从JSON文件name中重新加载吊销列表, 替换当前的内容, 文件格式为:
+//
+//	{
+//		"access_keys": ["AKPROD..."],
+//		"prefixes": ["AKTEST"],
+//		"issued_before": {"AKPROD...": "2026-10-18T00:00:00Z"}
+//	}
+//
+// 空的前缀被忽略
+func (l *RevocationList) LoadFile(name string) error {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return fmt.Errorf("读取吊销列表发生错误: %w", err)
+	}
+	var f revocationFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return fmt.Errorf("解析吊销列表发生错误: %w", err)
+	}
+	accessKeys := make(map[string]bool, len(f.AccessKeys))
+	for _, ak := range f.AccessKeys {
+		accessKeys[ak] = true
+	}
+	// 与RevokePrefix一样忽略空的前缀, 否则会吊销所有的accessKey
+	prefixes := make([]string, 0, len(f.Prefixes))
+	for _, prefix := range f.Prefixes {
+		if prefix != "" {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	issuedBefore := f.IssuedBefore
+	if issuedBefore == nil {
+		issuedBefore = make(map[string]time.Time)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.accessKeys = accessKeys
+	l.prefixes = prefixes
+	l.issuedBefore = issuedBefore
+	return nil
+}
+
+// RevokeAccessKey 吊销accessKey
+func (l *RevocationList) RevokeAccessKey(accessKey string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.accessKeys[accessKey] = true
+}
+
+// RevokePrefix 吊销所有以prefix开头的accessKey
+func (l *RevocationList) RevokePrefix(prefix string) {
+	if prefix == "" {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prefixes = append(l.prefixes, prefix)
+}
+
+// RevokeIssuedBefore 吊销accessKey在t之前派生的签名密钥和签发的临时会话凭证, accessKey本身仍然有效
+func (l *RevocationList) RevokeIssuedBefore(accessKey string, t time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if before, ok := l.issuedBefore[accessKey]; !ok || t.After(before) {
+		l.issuedBefore[accessKey] = t
+	}
+}
+
+// Revoked 返回accessKey是否已被吊销
+func (l *RevocationList) Revoked(accessKey string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.accessKeys[accessKey] {
+		return true
+	}
+	for _, prefix := range l.prefixes {
+		if strings.HasPrefix(accessKey, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// RevokedAt 返回accessKey在issuedAt派生或签发的凭证是否已被吊销
+func (l *RevocationList) RevokedAt(accessKey string, issuedAt time.Time) bool {
+	if l.Revoked(accessKey) {
+		return true
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	before, ok := l.issuedBefore[accessKey]
+	return ok && issuedAt.Before(before)
+}
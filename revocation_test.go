@@ -0,0 +1,114 @@
+package ginaksk
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRevocationList(t *testing.T) {
+	t.Cleanup(cleanup)
+	const (
+		ak = "202cb962ac59075b964b07152d234b70"
+		sk = "250cf8b51c773f3f8dc8b4be867a9a02"
+	)
+	keyFn := func(string) string {
+		return sk
+	}
+	issuer, _ := NewSessionIssuer([]byte("issuer-key"), time.Minute)
+	cred, _ := issuer.Issue(&Credential{AccessKey: ak})
+	f, _ := NewRequestFunc(cred.AccessKey, cred.SecretKey, WithSessionToken(cred.SessionToken))
+	sessionReq, _ := f(context.TODO(), "POST", `http://localhost:8080/e`, []byte(`{"param":"a"}`))
+	scope := CredentialScope{Date: time.Now(), Service: "orders", Purpose: "api"}
+	f, _ = NewRequestFunc(ak, DeriveSigningKey(sk, scope), WithCredentialScope(scope))
+	derivedReq, _ := f(context.TODO(), "POST", `http://localhost:8080/e`, []byte(`{"param":"a"}`))
+
+	tests := []struct {
+		name    string
+		revoke  func(l *RevocationList)
+		req     *http.Request
+		wantErr error
+	}{
+		{
+			name:   "NotRevoked",
+			revoke: func(l *RevocationList) { l.RevokeAccessKey("other") },
+			req:    generateRequest(ak, sk),
+		},
+		{
+			name:    "AccessKey",
+			revoke:  func(l *RevocationList) { l.RevokeAccessKey(ak) },
+			req:     generateRequest(ak, sk),
+			wantErr: ErrAccessKeyRevoked,
+		},
+		{
+			name:    "Prefix",
+			revoke:  func(l *RevocationList) { l.RevokePrefix(ak[:6]) },
+			req:     generateRequest(ak, sk),
+			wantErr: ErrAccessKeyRevoked,
+		},
+		{
+			name:    "SessionIssuedBefore",
+			revoke:  func(l *RevocationList) { l.RevokeIssuedBefore(ak, time.Now().Add(time.Second)) },
+			req:     sessionReq,
+			wantErr: ErrAccessKeyRevoked,
+		},
+		{
+			name:   "SessionIssuedAfter",
+			revoke: func(l *RevocationList) { l.RevokeIssuedBefore(ak, time.Now().Add(-time.Minute)) },
+			req:    sessionReq,
+		},
+		{
+			name:    "DerivedIssuedBefore",
+			revoke:  func(l *RevocationList) { l.RevokeIssuedBefore(ak, time.Now()) },
+			req:     derivedReq,
+			wantErr: ErrAccessKeyRevoked,
+		},
+		{
+			name:   "SecretKeyIssuedBefore",
+			revoke: func(l *RevocationList) { l.RevokeIssuedBefore(ak, time.Now()) },
+			req:    generateRequest(ak, sk),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewRevocationList()
+			tt.revoke(l)
+			opts := []Option{WithRevocationList(l), WithSessionIssuer(issuer), WithDerivedKeys("orders")}
+			if err := VerifyRequest(tt.req, keyFn, true, opts...); err != tt.wantErr {
+				t.Errorf("VerifyRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadRevocationList(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "revocations.json")
+	b := []byte(`{"access_keys":["ak1"],"prefixes":["AKTEST",""],"issued_before":{"ak2":"2026-10-18T00:00:00Z"}}`)
+	if err := ioutil.WriteFile(name, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	l, err := LoadRevocationList(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		ak       string
+		issuedAt time.Time
+		want     bool
+	}{
+		{ak: "ak1", issuedAt: day, want: true},
+		{ak: "AKTEST123", issuedAt: day, want: true},
+		{ak: "ak2", issuedAt: day.Add(-time.Hour), want: true},
+		{ak: "ak2", issuedAt: day, want: false},
+		{ak: "ak3", issuedAt: day, want: false},
+	}
+	for _, tt := range tests {
+		if got := l.RevokedAt(tt.ak, tt.issuedAt); got != tt.want {
+			t.Errorf("RevokedAt(%s, %s) = %v, want %v", tt.ak, tt.issuedAt, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package ginaksk
+
+import (
+	"strings"
+	"time"
+)
+
+// headerCredentialScope 派生签名密钥的范围, 格式为 日期/服务/用途, 如 20261018/orders/api
+const headerCredentialScope = `x-auth-credential-scope`
+
+// scopeDateLayout 范围中的日期格式, 使用UTC时间
+const scopeDateLayout = "20060102"
+
+// ErrCredentialScopeInvalid 请求的密钥范围无效
+var ErrCredentialScopeInvalid = newError("请求的密钥范围无效")
+
+// CredentialScope 派生签名密钥的范围, 派生的密钥只在Date当天对Service的Purpose有效
+type CredentialScope struct {
+	// Date 日期, 只使用UTC日期部分
+	Date time.Time
+	// Service 服务名称
+	Service string
+	// Purpose 用途
+	Purpose string
+}
+
+// String 返回 日期/服务/用途 格式的范围
+func (s CredentialScope) String() string {
+	return s.Date.UTC().Format(scopeDateLayout) + "/" + s.Service + "/" + s.Purpose
+}
+
+// ParseCredentialScope 解析 日期/服务/用途 格式的范围
+func ParseCredentialScope(s string) (CredentialScope, error) {
+	ss := strings.Split(s, "/")
+	if len(ss) != 3 || ss[1] == "" || ss[2] == "" {
+		return CredentialScope{}, ErrCredentialScopeInvalid
+	}
+	t, err := time.Parse(scopeDateLayout, ss[0])
+	if err != nil {
+		return CredentialScope{}, ErrCredentialScopeInvalid
+	}
+	return CredentialScope{Date: t, Service: ss[1], Purpose: ss[2]}, nil
+}
+
+// DeriveSigningKey 按照 secretKey → 日期 → 服务 → 用途 的顺序逐级计算HMAC, 返回编码后的签名密钥;
+// 客户端使用派生的签名密钥代替secretKey调用NewRequestFunc, 并通过WithCredentialScope声明范围
+func DeriveSigningKey(secretKey string, scope CredentialScope) string {
+	k := hmacRaw([]byte(secretKey), scope.Date.UTC().Format(scopeDateLayout))
+	k = hmacRaw(k, scope.Service)
+	k = hmacRaw(k, scope.Purpose)
+	return encoder.EncodeToString(k)
+}
+
+// credentialScope 解析并校验请求声明的范围, t为请求的时间戳
+func (v *validator) credentialScope(s string, t time.Time) (CredentialScope, error) {
+	if v.scopeService == "" {
+		return CredentialScope{}, ErrCredentialScopeInvalid
+	}
+	scope, err := ParseCredentialScope(s)
+	if err != nil {
+		return CredentialScope{}, err
+	}
+	if scope.Service != v.scopeService {
+		return CredentialScope{}, ErrCredentialScopeInvalid
+	}
+	if len(v.scopePurposes) > 0 && !v.scopePurposes[scope.Purpose] {
+		return CredentialScope{}, ErrCredentialScopeInvalid
+	}
+	if scope.Date.Format(scopeDateLayout) != t.UTC().Format(scopeDateLayout) {
+		return CredentialScope{}, ErrCredentialScopeInvalid
+	}
+	return scope, nil
+}
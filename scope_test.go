@@ -0,0 +1,109 @@
+package ginaksk
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDerivedKeys(t *testing.T) {
+	t.Cleanup(cleanup)
+	const sk = "250cf8b51c773f3f8dc8b4be867a9a02"
+	keyFn := func(string) string {
+		return sk
+	}
+	today := time.Now()
+	tests := []struct {
+		name    string
+		opts    []Option
+		scope   CredentialScope
+		key     string
+		wantErr bool
+	}{
+		{
+			name:  "Ok",
+			opts:  []Option{WithDerivedKeys("orders", "api")},
+			scope: CredentialScope{Date: today, Service: "orders", Purpose: "api"},
+		},
+		{
+			name:  "AnyPurpose",
+			opts:  []Option{WithDerivedKeys("orders")},
+			scope: CredentialScope{Date: today, Service: "orders", Purpose: "upload"},
+		},
+		{
+			name: "SecretKey",
+			opts: []Option{WithDerivedKeys("orders")},
+			key:  sk,
+		},
+		{
+			name:    "NotEnabled",
+			scope:   CredentialScope{Date: today, Service: "orders", Purpose: "api"},
+			wantErr: true,
+		},
+		{
+			name:    "OtherService",
+			opts:    []Option{WithDerivedKeys("orders")},
+			scope:   CredentialScope{Date: today, Service: "users", Purpose: "api"},
+			wantErr: true,
+		},
+		{
+			name:    "OtherPurpose",
+			opts:    []Option{WithDerivedKeys("orders", "api")},
+			scope:   CredentialScope{Date: today, Service: "orders", Purpose: "upload"},
+			wantErr: true,
+		},
+		{
+			name:    "Yesterday",
+			opts:    []Option{WithDerivedKeys("orders")},
+			scope:   CredentialScope{Date: today.AddDate(0, 0, -1), Service: "orders", Purpose: "api"},
+			wantErr: true,
+		},
+		{
+			name:    "SecretKeyWithScope",
+			opts:    []Option{WithDerivedKeys("orders")},
+			scope:   CredentialScope{Date: today, Service: "orders", Purpose: "api"},
+			key:     sk,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []RequestOption
+			key := tt.key
+			if tt.scope.Service != "" {
+				opts = append(opts, WithCredentialScope(tt.scope))
+				if key == "" {
+					key = DeriveSigningKey(sk, tt.scope)
+				}
+			}
+			f, _ := NewRequestFunc("202cb962ac59075b964b07152d234b70", key, opts...)
+			req, _ := f(context.TODO(), "POST", `http://localhost:8080/e`, []byte(`{"param":"a"}`))
+			if err := VerifyRequest(req, keyFn, false, tt.opts...); (err != nil) != tt.wantErr {
+				t.Errorf("VerifyRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseCredentialScope(t *testing.T) {
+	tests := []struct {
+		s       string
+		wantErr bool
+	}{
+		{s: "20261018/orders/api"},
+		{s: "2026-10-18/orders/api", wantErr: true},
+		{s: "20261018/orders", wantErr: true},
+		{s: "20261018//api", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			scope, err := ParseCredentialScope(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCredentialScope() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && scope.String() != tt.s {
+				t.Errorf("String() = %s, want %s", scope.String(), tt.s)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package ginaksk
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// headerSessionToken 临时会话凭证的令牌
+const headerSessionToken = `x-auth-session-token`
+
+const (
+	// sessionAccessKeyPrefix 临时accessKey的前缀
+	sessionAccessKeyPrefix = "STS"
+	// defaultSessionTTL 临时会话凭证默认的有效期
+	defaultSessionTTL = time.Hour
+)
+
+var (
+	// ErrSessionTokenInvalid 会话令牌无效
+	ErrSessionTokenInvalid = newError("会话令牌无效")
+	// ErrSessionTokenExpired 会话令牌过期
+	ErrSessionTokenExpired = newError("会话令牌过期")
+)
+
+// SessionCredential 临时会话凭证, 客户端使用AccessKey和SecretKey签名请求, 并通过WithSessionToken发送SessionToken
+type SessionCredential struct {
+	// AccessKey 临时accessKey
+	AccessKey string `json:"access_key"`
+	// SecretKey 临时secretKey
+	SecretKey string `json:"secret_key"`
+	// SessionToken 会话令牌
+	SessionToken string `json:"session_token"`
+	// ParentAccessKey 签发临时凭证的accessKey
+	ParentAccessKey string `json:"parent_access_key"`
+	// Scopes 临时凭证的权限范围
+	Scopes []string `json:"scopes,omitempty"`
+	// IssuedAt 签发时间
+	IssuedAt time.Time `json:"issued_at"`
+	// Expiration 过期时间
+	Expiration time.Time `json:"expiration"`
+}
+
+// sessionClaims 会话令牌中的声明
+type sessionClaims struct {
+	AccessKey       string   `json:"ak"`
+	ParentAccessKey string   `json:"pak"`
+	Scopes          []string `json:"scp,omitempty"`
+	IssuedAt        int64    `json:"iat"`
+	ExpiresAt       int64    `json:"exp"`
+}
+
+// SessionIssuer 签发临时会话凭证
+//
+// 会话令牌包含临时accessKey、签发者、权限范围和有效期, 使用签发密钥计算MAC防止篡改;
+// 临时secretKey由签发密钥和令牌计算得到, 服务端验证请求时只查询签发者的凭证
+type SessionIssuer struct {
+	key []byte
+	ttl time.Duration
+}
+
+// NewSessionIssuer 返回一个使用key签发临时凭证的SessionIssuer, ttl为临时凭证的有效期, 小于等于0时使用默认值1小时
+func NewSessionIssuer(key []byte, ttl time.Duration) (*SessionIssuer, error) {
+	if len(key) == 0 {
+		return nil, errors.New("签发密钥为空")
+	}
+	if ttl <= 0 {
+		ttl = defaultSessionTTL
+	}
+	return &SessionIssuer{key: key, ttl: ttl}, nil
+}
+
+// Issue 为已认证的凭证parent签发一个临时会话凭证, 如GetPrincipal返回的Principal.Credential;
+// scopes为临时凭证的权限范围, 必须包含在parent的权限范围之内, 否则返回ErrScopeDenied
+func (s *SessionIssuer) Issue(parent *Credential, scopes ...string) (*SessionCredential, error) {
+	if parent == nil || parent.AccessKey == "" {
+		return nil, ErrAccessKeyEmpty
+	}
+	for _, scope := range scopes {
+		if !hasScope(parent.Scopes, scope) {
+			return nil, ErrScopeDenied
+		}
+	}
+	parentAccessKey := parent.AccessKey
+	b, err := randomBytes(accessKeyRandomLength)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	claims := &sessionClaims{
+		AccessKey:       sessionAccessKeyPrefix + encoder.EncodeToString(b),
+		ParentAccessKey: parentAccessKey,
+		Scopes:          scopes,
+		IssuedAt:        now.Unix(),
+		ExpiresAt:       now.Add(s.ttl).Unix(),
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+	p := base64.RawURLEncoding.EncodeToString(payload)
+	return &SessionCredential{
+		AccessKey:       claims.AccessKey,
+		SecretKey:       s.secretKey(p),
+		SessionToken:    p + "." + base64.RawURLEncoding.EncodeToString(s.mac(p)),
+		ParentAccessKey: parentAccessKey,
+		Scopes:          scopes,
+		IssuedAt:        time.Unix(claims.IssuedAt, 0),
+		Expiration:      time.Unix(claims.ExpiresAt, 0),
+	}, nil
+}
+
+// parse 校验会话令牌, 返回令牌中的声明和临时secretKey
+func (s *SessionIssuer) parse(token string) (*sessionClaims, string, error) {
+	i := strings.IndexByte(token, '.')
+	if i < 0 {
+		return nil, "", ErrSessionTokenInvalid
+	}
+	p := token[:i]
+	mac, err := base64.RawURLEncoding.DecodeString(token[i+1:])
+	if err != nil || !hmac.Equal(mac, s.mac(p)) {
+		return nil, "", ErrSessionTokenInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(p)
+	if err != nil {
+		return nil, "", ErrSessionTokenInvalid
+	}
+	claims := new(sessionClaims)
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, "", ErrSessionTokenInvalid
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, "", ErrSessionTokenExpired
+	}
+	return claims, s.secretKey(p), nil
+}
+
+// mac 计算令牌的MAC
+func (s *SessionIssuer) mac(payload string) []byte {
+	return hmacRaw(s.key, "ginaksk-session-token\n"+payload)
+}
+
+// secretKey 计算令牌对应的临时secretKey
+func (s *SessionIssuer) secretKey(payload string) string {
+	return encoder.EncodeToString(hmacRaw(s.key, "ginaksk-session-secret\n"+payload))
+}
@@ -0,0 +1,186 @@
+package ginaksk
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSessionIssuer(t *testing.T) {
+	t.Cleanup(cleanup)
+	issuer, err := NewSessionIssuer([]byte("issuer-key"), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := NewSessionIssuer([]byte("other-key"), time.Minute)
+	expired := &SessionIssuer{key: []byte("issuer-key"), ttl: -time.Minute}
+	parent := &Credential{AccessKey: "202cb962ac59075b964b07152d234b70", Scopes: []string{"orders:*"}}
+	cred, err := issuer.Issue(parent, "orders:read")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFn := func(ak string) string {
+		if ak == parent.AccessKey {
+			return "250cf8b51c773f3f8dc8b4be867a9a02"
+		}
+		return ""
+	}
+	request := func(cred *SessionCredential, token string) *http.Request {
+		f, _ := NewRequestFunc(cred.AccessKey, cred.SecretKey, WithSessionToken(token))
+		r, _ := f(context.TODO(), "POST", `http://localhost:8080/e`, []byte(`{"param":"a"}`))
+		return r
+	}
+	expiredCred, _ := expired.Issue(parent)
+	otherCred, _ := issuer.Issue(parent)
+	tests := []struct {
+		name    string
+		req     *http.Request
+		opts    []Option
+		wantErr error
+	}{
+		{
+			name: "Ok",
+			req:  request(cred, cred.SessionToken),
+			opts: []Option{WithSessionIssuer(issuer)},
+		},
+		{
+			name:    "NotEnabled",
+			req:     request(cred, cred.SessionToken),
+			wantErr: ErrSessionTokenInvalid,
+		},
+		{
+			name:    "OtherIssuer",
+			req:     request(cred, cred.SessionToken),
+			opts:    []Option{WithSessionIssuer(other)},
+			wantErr: ErrSessionTokenInvalid,
+		},
+		{
+			name:    "Expired",
+			req:     request(expiredCred, expiredCred.SessionToken),
+			opts:    []Option{WithSessionIssuer(issuer)},
+			wantErr: ErrSessionTokenExpired,
+		},
+		{
+			name:    "OtherToken",
+			req:     request(cred, otherCred.SessionToken),
+			opts:    []Option{WithSessionIssuer(issuer)},
+			wantErr: ErrSessionTokenInvalid,
+		},
+		{
+			name:    "TamperedToken",
+			req:     request(cred, "x"+cred.SessionToken),
+			opts:    []Option{WithSessionIssuer(issuer)},
+			wantErr: ErrSessionTokenInvalid,
+		},
+		{
+			name:    "WithoutToken",
+			req:     generateRequest(cred.AccessKey, cred.SecretKey),
+			opts:    []Option{WithSessionIssuer(issuer)},
+			wantErr: ErrSecretKeyEmpty,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := VerifyRequest(tt.req, keyFn, false, tt.opts...); err != tt.wantErr {
+				t.Errorf("VerifyRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSessionIssuerScopes(t *testing.T) {
+	issuer, _ := NewSessionIssuer([]byte("issuer-key"), time.Minute)
+	tests := []struct {
+		name    string
+		parent  *Credential
+		scopes  []string
+		wantErr error
+	}{
+		{name: "Subset", parent: &Credential{AccessKey: "ak", Scopes: []string{"orders:read", "users:read"}}, scopes: []string{"orders:read"}},
+		{name: "Wildcard", parent: &Credential{AccessKey: "ak", Scopes: []string{"orders:*"}}, scopes: []string{"orders:read", "orders:write"}},
+		{name: "NoScopes", parent: &Credential{AccessKey: "ak", Scopes: []string{"orders:read"}}},
+		{name: "Escalate", parent: &Credential{AccessKey: "partner", Scopes: []string{"orders:read"}}, scopes: []string{"*"}, wantErr: ErrScopeDenied},
+		{name: "EscalateWildcard", parent: &Credential{AccessKey: "partner", Scopes: []string{"orders:read"}}, scopes: []string{"orders:*"}, wantErr: ErrScopeDenied},
+		{name: "ParentWithoutScopes", parent: &Credential{AccessKey: "partner"}, scopes: []string{"orders:read"}, wantErr: ErrScopeDenied},
+		{name: "NilParent", wantErr: ErrAccessKeyEmpty},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cred, err := issuer.Issue(tt.parent, tt.scopes...)
+			if err != tt.wantErr {
+				t.Fatalf("Issue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && len(cred.Scopes) != len(tt.scopes) {
+				t.Errorf("Scopes = %v, want %v", cred.Scopes, tt.scopes)
+			}
+		})
+	}
+}
+
+func TestSessionInheritsParent(t *testing.T) {
+	t.Cleanup(cleanup)
+	gin.SetMode(gin.TestMode)
+	issuer, _ := NewSessionIssuer([]byte("issuer-key"), time.Minute)
+	policy, _ := ParsePolicy([]byte(`{"Statement": [{"Effect": "Allow", "Action": "GET /orders/**"}]}`))
+	parents := map[string]*Credential{
+		"partner":  {AccessKey: "partner", SecretKey: "sk", Scopes: []string{"orders:read"}, AllowedCIDRs: []string{"10.0.0.0/8"}},
+		"readonly": {AccessKey: "readonly", SecretKey: "sk", Policy: policy},
+		"deleted":  {AccessKey: "deleted", SecretKey: "sk"},
+		"disabled": {AccessKey: "disabled", SecretKey: "sk"},
+		"narrowed": {AccessKey: "narrowed", SecretKey: "sk", Scopes: []string{"orders:read", "orders:write"}},
+	}
+	credFn := func(ak string) (*Credential, error) {
+		switch ak {
+		case "deleted":
+			return nil, nil
+		case "disabled":
+			return &Credential{AccessKey: ak}, nil
+		case "narrowed":
+			// 签发后移除了orders:write
+			return &Credential{AccessKey: ak, SecretKey: "sk", Scopes: []string{"orders:read"}}, nil
+		}
+		return parents[ak], nil
+	}
+	var gotErr error
+	e := gin.New()
+	e.Use(New(credFn, WithSessionIssuer(issuer), WithErrorHandler(func(c *gin.Context, err error) {
+		gotErr = err
+		handleError(c, err)
+	})))
+	e.Any("/orders/*path", func(c *gin.Context) {})
+	e.POST("/write/*path", RequireScopes("orders:write"), func(c *gin.Context) {})
+	request := func(parent, method, path, remoteAddr string) *http.Request {
+		session, _ := issuer.Issue(parents[parent], parents[parent].Scopes...)
+		f, _ := NewRequestFunc(session.AccessKey, session.SecretKey, WithSessionToken(session.SessionToken))
+		req, _ := f(context.TODO(), method, `http://localhost`+path, nil)
+		req.RemoteAddr = remoteAddr
+		return req
+	}
+	tests := []struct {
+		name    string
+		req     *http.Request
+		wantErr error
+	}{
+		{name: "AllowedSource", req: request("partner", "GET", "/orders/1", "10.1.2.3:1234")},
+		{name: "SourceNotAllowed", req: request("partner", "GET", "/orders/1", "203.0.113.9:1234"), wantErr: ErrSourceNotAllowed},
+		{name: "PolicyAllowed", req: request("readonly", "GET", "/orders/1", "203.0.113.9:1234")},
+		{name: "PolicyDenied", req: request("readonly", "DELETE", "/orders/1", "203.0.113.9:1234"), wantErr: ErrPolicyDenied},
+		{name: "ParentDeleted", req: request("deleted", "GET", "/orders/1", "203.0.113.9:1234"), wantErr: ErrSessionTokenInvalid},
+		{name: "ParentDisabled", req: request("disabled", "GET", "/orders/1", "203.0.113.9:1234"), wantErr: ErrSecretKeyEmpty},
+		{name: "ScopeKept", req: request("narrowed", "GET", "/orders/1", "203.0.113.9:1234")},
+		{name: "ScopeRemoved", req: request("narrowed", "POST", "/write/1", "203.0.113.9:1234"), wantErr: ErrScopeDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotErr = nil
+			e.ServeHTTP(httptest.NewRecorder(), tt.req)
+			if gotErr != tt.wantErr {
+				t.Errorf("error = %v, want %v", gotErr, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package ginaksk
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// spoolKey gin.Context中保存暂存的请求内容的key
+const spoolKey = "ginaksk.spool"
+
+// defaultSpoolThreshold 默认的内存暂存上限, 与gin的MaxMultipartMemory一致
+const defaultSpoolThreshold = 32 << 20
+
+// spooledBody 暂存在内存或临时文件中的请求内容, 可以使用Seek重新读取, Close时删除临时文件
+type spooledBody struct {
+	r io.ReadSeeker
+	// f 临时文件, 请求内容没有超过内存暂存上限时为nil
+	f *os.File
+}
+
+// Read 读取请求内容
+func (b *spooledBody) Read(p []byte) (int, error) {
+	return b.r.Read(p)
+}
+
+// Seek 设置下次读取的位置
+func (b *spooledBody) Seek(offset int64, whence int) (int64, error) {
+	return b.r.Seek(offset, whence)
+}
+
+// Close 关闭并删除临时文件, 可以多次调用
+func (b *spooledBody) Close() error {
+	if b.f == nil {
+		return nil
+	}
+	f := b.f
+	b.f = nil
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// spool 读取r并计算哈希值, 不超过threshold字节时暂存在内存中, 否则写入临时文件; 返回暂存的请求内容, 长度和哈希值
+func spool(r io.Reader, threshold int64) (*spooledBody, int64, []byte, error) {
+	h := hashFunc()
+	var buf bytes.Buffer
+	n, err := io.CopyN(io.MultiWriter(&buf, h), r, threshold+1)
+	if err == io.EOF {
+		return &spooledBody{r: bytes.NewReader(buf.Bytes())}, n, h.Sum(nil), nil
+	} else if err != nil {
+		return nil, 0, nil, readError(err)
+	}
+	f, err := ioutil.TempFile("", "ginaksk-")
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("创建临时文件发生错误: %s", err)
+	}
+	b := &spooledBody{r: f, f: f}
+	if _, err = buf.WriteTo(f); err != nil {
+		b.Close()
+		return nil, 0, nil, fmt.Errorf("写入临时文件发生错误: %s", err)
+	}
+	m, err := io.Copy(io.MultiWriter(f, h), r)
+	if err != nil {
+		b.Close()
+		return nil, 0, nil, readError(err)
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		b.Close()
+		return nil, 0, nil, fmt.Errorf("读取临时文件发生错误: %s", err)
+	}
+	return b, n + m, h.Sum(nil), nil
+}
+
+// readError 包装读取请求内容的错误, ErrBodyTooLarge保持不变
+func readError(err error) error {
+	if err == ErrBodyTooLarge {
+		return err
+	}
+	return fmt.Errorf("读取Body发生错误: %s", err)
+}
+
+// isMultipart 返回Content-Type是否是multipart/form-data
+func isMultipart(contentType string) bool {
+	t, _, err := mime.ParseMediaType(contentType)
+	return err == nil && t == "multipart/form-data"
+}
+
+// spoolBody 暂存并验证请求内容, 按照原始字节计算哈希值, d不为nil时比较请求摘要代替bodyhash,
+// 验证通过后使用暂存的请求内容替换c.Request.Body
+func (v *validator) spoolBody(c *gin.Context, bodyhash string, d *digester) error {
+	if c.Request.Body == nil {
+		c.Request.Body = http.NoBody
+	}
+	var r io.Reader = c.Request.Body
+	if d != nil {
+		r = io.TeeReader(r, d)
+	}
+	b, n, sum, err := spool(r, v.spoolThreshold)
+	if err != nil {
+		return err
+	}
+	length := int64(-1)
+	if v.strict {
+		length = c.Request.ContentLength
+	}
+	if d != nil {
+		if length >= 0 && n != length {
+			err = ErrContentLengthMismatch
+		} else {
+			err = d.verify()
+		}
+	} else {
+		err = verifySum(sum, n, length, bodyhash, v.strict)
+	}
+	if err != nil {
+		b.Close()
+		return err
+	}
+	c.Request.Body.Close()
+	c.Request.Body = b
+	c.Set(spoolKey, b)
+	return nil
+}
+
+// releaseSpool 删除暂存请求内容的临时文件
+func releaseSpool(c *gin.Context) {
+	if v, ok := c.Get(spoolKey); ok {
+		v.(*spooledBody).Close()
+	}
+}
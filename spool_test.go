@@ -0,0 +1,121 @@
+package ginaksk
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMultipartSpool(t *testing.T) {
+	t.Cleanup(cleanup)
+	gin.SetMode(gin.TestMode)
+	credFn := func(ak string) (*Credential, error) {
+		return &Credential{AccessKey: ak, SecretKey: "sk"}, nil
+	}
+	var (
+		content []byte
+		spooled string
+	)
+	e := gin.New()
+	e.Use(New(credFn, WithMultipartSpool(1024)))
+	e.POST("/upload", func(c *gin.Context) {
+		if b, ok := c.Request.Body.(*spooledBody); ok && b.f != nil {
+			spooled = b.f.Name()
+		}
+		fh, err := c.FormFile("file")
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		f, _ := fh.Open()
+		defer f.Close()
+		content, _ = ioutil.ReadAll(f)
+	})
+	request := func(data []byte, tamper bool, opts ...RequestOption) *http.Request {
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		fw, _ := w.CreateFormFile("file", "data.bin")
+		fw.Write(data)
+		w.Close()
+		f, _ := NewRequestFunc("ak", "sk", append(opts, WithContentType(w.FormDataContentType()))...)
+		req, _ := f(context.TODO(), "POST", `http://localhost/upload`, buf.Bytes())
+		if tamper {
+			b := buf.Bytes()
+			b[len(b)/2] ^= 1
+			req.Body = ioutil.NopCloser(bytes.NewReader(b))
+		}
+		return req
+	}
+	small := []byte("hello")
+	large := bytes.Repeat([]byte("0123456789"), 1000)
+	tests := []struct {
+		name        string
+		req         *http.Request
+		want        int
+		wantContent []byte
+		wantSpooled bool
+	}{
+		{name: "Memory", req: request(small, false), want: http.StatusOK, wantContent: small},
+		{name: "TempFile", req: request(large, false), want: http.StatusOK, wantContent: large, wantSpooled: true},
+		{name: "Tampered", req: request(large, true), want: http.StatusUnauthorized},
+		{name: "ContentDigest", req: request(large, false, WithContentDigest()), want: http.StatusOK, wantContent: large, wantSpooled: true},
+		{name: "ContentDigestTampered", req: request(large, true, WithContentDigest()), want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, spooled = nil, ""
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, tt.req)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d, body: %s", w.Code, tt.want, w.Body)
+			}
+			if !bytes.Equal(content, tt.wantContent) {
+				t.Errorf("content length = %d, want %d", len(content), len(tt.wantContent))
+			}
+			if (spooled != "") != tt.wantSpooled {
+				t.Errorf("spooled = %q, wantSpooled %v", spooled, tt.wantSpooled)
+			}
+			// 请求结束后删除临时文件
+			if spooled != "" {
+				if _, err := os.Stat(spooled); !os.IsNotExist(err) {
+					t.Errorf("临时文件没有删除: %s", spooled)
+				}
+			}
+		})
+	}
+}
+
+func TestSpool(t *testing.T) {
+	data := bytes.Repeat([]byte("abc"), 100)
+	for _, threshold := range []int64{10, 300, 1000} {
+		b, n, sum, err := spool(bytes.NewReader(data), threshold)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != int64(len(data)) || !bytes.Equal(sum, hashSum(data)) {
+			t.Errorf("threshold %d: n = %d, sum = %x", threshold, n, sum)
+		}
+		if (b.f != nil) != (threshold < int64(len(data))) {
+			t.Errorf("threshold %d: temp file = %v", threshold, b.f != nil)
+		}
+		// 可以重新读取
+		for i := 0; i < 2; i++ {
+			got, _ := ioutil.ReadAll(b)
+			if !bytes.Equal(got, data) {
+				t.Errorf("threshold %d: read %d bytes", threshold, len(got))
+			}
+			b.Seek(0, 0)
+		}
+		if err := b.Close(); err != nil {
+			t.Error(err)
+		}
+		b.Close()
+	}
+}
@@ -0,0 +1,103 @@
+package ginaksk
+
+import (
+	"context"
+	"crypto/hmac"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TrailerPayload x-auth-body-hash的取值, 表示请求内容的哈希值在HTTP尾部(trailer)中发送;
+// 尾部的x-auth-body-hash为请求内容的哈希值, x-auth-trailer-signature为
+// HMAC(signingKey, "ginaksk-trailer\n" + x-auth-signature + "\n" + x-auth-body-hash)
+const TrailerPayload = "STREAMING-TRAILER"
+
+// ErrTrailerPayloadDenied 服务端没有使用WithTrailerPayload时不允许在HTTP尾部发送请求内容的哈希值
+var ErrTrailerPayloadDenied = newStatusError(http.StatusForbidden, "不允许在HTTP尾部发送请求内容哈希值")
+
+// trailerStringToSign 返回尾部的待签名字符串
+func trailerStringToSign(signature, bodyhash string) string {
+	return "ginaksk-trailer\n" + signature + "\n" + bodyhash
+}
+
+// NewTrailerRequestFunc 返回一个StreamingRequestFunc, 请求使用chunked编码发送,
+// 读取完请求内容后在HTTP尾部发送x-auth-body-hash和x-auth-trailer-signature; opts为可选配置, 请求内容不规范化
+func NewTrailerRequestFunc(ak, sk string, opts ...RequestOption) (StreamingRequestFunc, error) {
+	o, err := newRequestOptions(ak, sk, opts)
+	if err != nil {
+		return nil, err
+	}
+	fn := func(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, method, url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("创建HTTP请求发生错误:%w", err)
+		}
+		signature, err := o.sign(req, ak, sk, TrailerPayload)
+		if err != nil {
+			return nil, err
+		}
+		if body == nil {
+			body = http.NoBody
+		}
+		// 声明尾部, 读取到EOF时设置
+		req.Trailer = http.Header{}
+		req.Trailer.Set(headerBodyHash, "")
+		req.Trailer.Set(headerTrailerSignature, "")
+		req.Body = ioutil.NopCloser(&trailerSigner{r: body, h: hashFunc(), key: []byte(sk), signature: signature, trailer: req.Trailer})
+		req.ContentLength = -1
+		return req, nil
+	}
+	return fn, nil
+}
+
+// trailerSigner 边读取边计算请求内容的哈希值, 读取到EOF时设置请求的尾部
+type trailerSigner struct {
+	r         io.Reader
+	h         hash.Hash
+	key       []byte
+	signature string
+	trailer   http.Header
+	done      bool
+}
+
+// Read 读取请求内容并计算哈希值
+func (s *trailerSigner) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	s.h.Write(p[:n])
+	if err == io.EOF && !s.done {
+		s.done = true
+		bodyhash := encoder.EncodeToString(s.h.Sum(nil))
+		s.trailer.Set(headerBodyHash, bodyhash)
+		s.trailer.Set(headerTrailerSignature, encoder.EncodeToString(hmacRaw(s.key, trailerStringToSign(s.signature, bodyhash))))
+	}
+	return n, err
+}
+
+// trailerBody 使用hashingBody替换请求内容, 由处理函数读取, 读取到EOF时验证尾部的签名和请求内容的哈希值;
+// 客户端必须在Trailer头部中声明尾部, 否则HTTP/2和修改过的请求可能读取不到尾部
+func trailerBody(c *gin.Context, key, signature string) {
+	r := c.Request
+	if r.Body == nil {
+		r.Body = http.NoBody
+	}
+	b := newHashingBody(r.Body, "")
+	b.strict = true
+	b.expected = func() (string, error) {
+		bodyhash := r.Trailer.Get(headerBodyHash)
+		if bodyhash == "" {
+			return "", ErrBodyHashEmpty
+		}
+		mac, err := encoder.DecodeString(r.Trailer.Get(headerTrailerSignature))
+		if err != nil || !hmac.Equal(mac, hmacRaw([]byte(key), trailerStringToSign(signature, bodyhash))) {
+			return "", ErrBodyInvalid
+		}
+		return bodyhash, nil
+	}
+	c.Request.Body = b
+	c.Set(bodyKey, b)
+}
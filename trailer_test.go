@@ -0,0 +1,151 @@
+package ginaksk
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tamperReader 读取时修改请求内容, flip为true时修改第off个字节, modify在读取到EOF时调用
+type tamperReader struct {
+	r      io.Reader
+	flip   bool
+	off    int
+	pos    int
+	modify func()
+}
+
+func (t *tamperReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if t.flip && t.off >= t.pos && t.off < t.pos+n {
+		p[t.off-t.pos] ^= 1
+		t.flip = false
+	}
+	t.pos += n
+	if err == io.EOF && t.modify != nil {
+		t.modify()
+	}
+	return n, err
+}
+
+func TestTrailerPayload(t *testing.T) {
+	t.Cleanup(cleanup)
+	gin.SetMode(gin.TestMode)
+	credFn := func(ak string) (*Credential, error) {
+		return &Credential{AccessKey: ak, SecretKey: "sk"}, nil
+	}
+	var content []byte
+	e := gin.New()
+	e.Use(New(credFn, WithTrailerPayload()))
+	e.PUT("/upload", func(c *gin.Context) {
+		content, _ = ioutil.ReadAll(c.Request.Body)
+	})
+	s := httptest.NewServer(e)
+	defer s.Close()
+
+	data := bytes.Repeat([]byte("0123456789"), 10000)
+	tests := []struct {
+		name   string
+		body   []byte
+		tamper func(req *http.Request) *tamperReader
+		want   int
+	}{
+		{name: "Ok", body: data, want: http.StatusOK},
+		{name: "Empty", want: http.StatusOK},
+		{name: "TamperedBody", body: data, tamper: func(req *http.Request) *tamperReader {
+			return &tamperReader{r: req.Body, flip: true}
+		}, want: http.StatusUnauthorized},
+		{name: "ForgedTrailer", body: data, tamper: func(req *http.Request) *tamperReader {
+			// 修改请求内容并重新计算哈希值, 但是无法计算尾部的签名
+			b := append([]byte(nil), data...)
+			b[0] ^= 1
+			return &tamperReader{r: req.Body, flip: true, modify: func() {
+				req.Trailer.Set(headerBodyHash, encoder.EncodeToString(hashSum(b)))
+			}}
+		}, want: http.StatusUnauthorized},
+		{name: "MissingTrailer", body: data, tamper: func(req *http.Request) *tamperReader {
+			return &tamperReader{r: req.Body, modify: func() {
+				req.Trailer.Set(headerBodyHash, "")
+			}}
+		}, want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content = nil
+			f, _ := NewTrailerRequestFunc("ak", "sk")
+			req, _ := f(context.TODO(), "PUT", s.URL+"/upload", bytes.NewReader(tt.body))
+			if tt.tamper != nil {
+				req.Body = ioutil.NopCloser(tt.tamper(req))
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+			if tt.want == http.StatusOK && !bytes.Equal(content, tt.body) {
+				t.Errorf("content length = %d, want %d", len(content), len(tt.body))
+			}
+		})
+	}
+}
+
+func TestTrailerPayloadOption(t *testing.T) {
+	t.Cleanup(cleanup)
+	gin.SetMode(gin.TestMode)
+	credFn := func(ak string) (*Credential, error) {
+		return &Credential{AccessKey: ak, SecretKey: "sk"}, nil
+	}
+	// 只解码第一个JSON值, 不读取到EOF
+	bind := func(c *gin.Context) {
+		var v struct{ N int }
+		if err := c.ShouldBindJSON(&v); err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		c.Status(http.StatusOK)
+	}
+	e := gin.New()
+	e.PUT("/disabled", New(credFn), bind)
+	e.PUT("/bind", New(credFn, WithTrailerPayload()), bind)
+	s := httptest.NewServer(e)
+	defer s.Close()
+
+	data := append([]byte(`{"n":1}`), bytes.Repeat([]byte(" "), 100000)...)
+	tests := []struct {
+		name   string
+		path   string
+		tamper bool
+		want   int
+	}{
+		{name: "Disabled", path: "/disabled", want: http.StatusForbidden},
+		{name: "Ok", path: "/bind", want: http.StatusOK},
+		{name: "TamperedUnread", path: "/bind", tamper: true, want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, _ := NewTrailerRequestFunc("ak", "sk")
+			req, _ := f(context.TODO(), "PUT", s.URL+tt.path, bytes.NewReader(data))
+			if tt.tamper {
+				// 修改处理函数没有读取的最后一个字节
+				req.Body = ioutil.NopCloser(&tamperReader{r: req.Body, flip: true, off: len(data) - 1})
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package ginaksk
+
+import "net/http"
+
+// UnsignedPayload x-auth-body-hash的取值, 表示请求内容未签名, 服务端不检查请求内容的哈希值
+const UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// ErrUnsignedPayloadDenied 路由和凭证都不允许使用未签名的请求内容
+var ErrUnsignedPayloadDenied = newStatusError(http.StatusForbidden, "不允许使用未签名的请求内容")
+
+// unsignedPayload 返回路径p或凭证cred是否允许使用未签名的请求内容
+func (o *options) unsignedPayload(p string, cred *Credential) bool {
+	if cred.AllowUnsignedPayload {
+		return true
+	}
+	for _, pattern := range o.unsignedPaths {
+		if matchPath(pattern, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package ginaksk
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestUnsignedPayload(t *testing.T) {
+	t.Cleanup(cleanup)
+	gin.SetMode(gin.TestMode)
+	credFn := func(ak string) (*Credential, error) {
+		return &Credential{AccessKey: ak, SecretKey: "sk", AllowUnsignedPayload: ak == "bulk"}, nil
+	}
+	var unsigned bool
+	e := gin.New()
+	e.Use(New(credFn, WithStrictBodyHash(), WithUnsignedPayloadPaths("/upload/**")))
+	handler := func(c *gin.Context) {
+		p, _ := GetPrincipal(c)
+		unsigned = p.UnsignedPayload
+	}
+	e.POST("/upload/:name", handler)
+	e.POST("/orders", handler)
+	request := func(ak, path string, opts ...RequestOption) *http.Request {
+		f, _ := NewRequestFunc(ak, "sk", opts...)
+		req, _ := f(context.TODO(), "POST", `http://localhost`+path, []byte("data"))
+		return req
+	}
+	tests := []struct {
+		name         string
+		req          *http.Request
+		want         int
+		wantUnsigned bool
+	}{
+		{name: "Route", req: request("ak", "/upload/a.bin", WithRequestUnsignedPayload()), want: http.StatusOK, wantUnsigned: true},
+		{name: "RouteSigned", req: request("ak", "/upload/a.bin"), want: http.StatusOK},
+		{name: "Credential", req: request("bulk", "/orders", WithRequestUnsignedPayload()), want: http.StatusOK, wantUnsigned: true},
+		{name: "Denied", req: request("ak", "/orders", WithRequestUnsignedPayload()), want: http.StatusForbidden},
+		{name: "Signed", req: request("ak", "/orders"), want: http.StatusOK},
+		// x-auth-body-hash参与签名, 不能改为UNSIGNED-PAYLOAD
+		{name: "Downgrade", req: func() *http.Request {
+			req := request("ak", "/upload/a.bin")
+			req.Header.Set(headerBodyHash, UnsignedPayload)
+			req.Body = ioutil.NopCloser(bytes.NewReader([]byte("tampered")))
+			return req
+		}(), want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unsigned = false
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, tt.req)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d, body: %s", w.Code, tt.want, w.Body)
+			}
+			if unsigned != tt.wantUnsigned {
+				t.Errorf("UnsignedPayload = %v, want %v", unsigned, tt.wantUnsigned)
+			}
+		})
+	}
+}